@@ -0,0 +1,265 @@
+package golang_oauth
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+// SetAssertionAudience sets values accepted in aud claim of client assertions and JWT bearer grants,
+// usually token endpoint url and issuer identifier of the server, empty values are ignored as aud must
+// identify the server, assertions are rejected until a value is set
+func (c *tokenCodec) SetAssertionAudience(audience ...string) {
+	c.audience = nil
+	for _, value := range audience {
+		if value != "" {
+			c.audience = append(c.audience, value)
+		}
+	}
+}
+
+// encodeClientJWKS validates JWK Set registered for client and encodes it for storage
+func encodeClientJWKS(jwks util.JWKSet) (string, error) {
+	if len(jwks.Keys) == 0 {
+		return "", errors.New(util.InvalidJWKS)
+	}
+	for _, key := range jwks.Keys {
+		if _, err := key.PublicKey(); err != nil {
+			return "", errors.New(util.InvalidJWKS)
+		}
+	}
+	encoded, err := json.Marshal(jwks)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// clientCredentials returns client credentials carried by info, assertion and authentication method are set
+// only when info can carry them
+func clientCredentials(info model.TokenInfo) model.ClientCredentials {
+	credentials := model.ClientCredentials{ClientID: info.GetClientID(), ClientSecret: info.GetClientSecret()}
+	if assertionInfo, ok := info.(model.ClientAssertionInfo); ok {
+		credentials.ClientAssertionType = assertionInfo.GetClientAssertionType()
+		credentials.ClientAssertion = assertionInfo.GetClientAssertion()
+	}
+	if methodInfo, ok := info.(model.AuthMethodInfo); ok {
+		credentials.AuthMethod = methodInfo.GetAuthMethod()
+	}
+	return credentials
+}
+
+// authenticateInfo authenticates client presenting info by secret or client assertion with AuthenticateClient,
+// sets id of the client on info, as client authenticating by assertion may omit it, and fills its default token lifetimes,
+// grantType grant the client must be registered for
+func (m *Manager) authenticateInfo(info model.TokenInfo, grantType string) (model.Clients, error) {
+	client, err := m.AuthenticateClient(clientCredentials(info))
+	if err != nil {
+		return client, err
+	}
+	if !client.AllowsGrant(grantType) {
+		return client, errors.New(util.UnauthorizedClient)
+	}
+	info.SetClientID(client.ID)
+	applyClientLifetimes(info, client)
+	return client, nil
+}
+
+// assertionIssuer returns id of client which issued assertion, it is read from unverified iss claim only to find the client keys
+func assertionIssuer(assertion string) (uuid.UUID, error) {
+	var claims model.AssertionClaims
+	if err := util.DecodeJWTClaims(assertion, &claims); err != nil {
+		return uuid.Nil, errors.New(util.InvalidAssertion)
+	}
+	issuer, err := uuid.Parse(claims.Issuer)
+	if err != nil {
+		return uuid.Nil, errors.New(util.InvalidAssertion)
+	}
+	return issuer, nil
+}
+
+// verifyAssertion verifies signature of assertion with keys registered for client and validates its claims (RFC 7523 3),
+// issuer must be the client, audience one of configured values and expiry at most util.MaxAssertionLifetime away,
+// replay is not checked here as the jti is recorded by caller only after the whole request is validated
+func (c *tokenCodec) verifyAssertion(assertion string, client model.Clients) (*model.AssertionClaims, error) {
+	if len(c.audience) == 0 {
+		return nil, errors.New(util.MissingAudience)
+	}
+	if client.ID == uuid.Nil || client.Revoked || client.JWKS == "" {
+		return nil, errors.New(util.InvalidClient)
+	}
+	var jwks util.JWKSet
+	if err := json.Unmarshal([]byte(client.JWKS), &jwks); err != nil {
+		return nil, errors.New(util.InvalidJWKS)
+	}
+
+	var claims model.AssertionClaims
+	verified := false
+	for _, jwk := range jwks.Keys {
+		_, err := util.ParseJWT(assertion, &claims, func(header util.JWTHeader) (crypto.PublicKey, error) {
+			if (header.Kid != "" && header.Kid != jwk.Kid) || (jwk.Alg != "" && jwk.Alg != header.Alg) {
+				return nil, errors.New(util.UnknownKey)
+			}
+			return jwk.PublicKey()
+		})
+		if err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New(util.InvalidAssertion)
+	}
+
+	now := time.Now().Unix()
+	if claims.Issuer != client.ID.String() || claims.Subject == "" || claims.ID == "" || !claims.Audience.Contains(c.audience...) {
+		return nil, errors.New(util.InvalidAssertion)
+	}
+	if claims.ExpiresAt == 0 || claims.ExpiresAt > now+util.MaxAssertionLifetime ||
+		claims.NotBefore > now+util.ClockSkew || claims.IssuedAt > now+util.ClockSkew {
+		return nil, errors.New(util.InvalidAssertion)
+	}
+	if claims.ExpiresAt < now-util.ClockSkew {
+		return nil, errors.New(util.AssertionExpired)
+	}
+	return &claims, nil
+}
+
+// AuthenticateClient authenticates client by secret or, when assertion is given, by JWT client assertion
+// signed with one of its registered keys (private_key_jwt, RFC 7523 2.2), assertion type must be
+// util.ClientAssertionTypeJWT, client id may be uuid.Nil with assertion as the client is its issuer,
+// secret sent with other authentication method than the client registered is rejected,
+// invalid, expired or replayed client assertion fails client authentication with util.InvalidClient (RFC 7523 3.2)
+func (m *Manager) AuthenticateClient(credentials model.ClientCredentials) (model.Clients, error) {
+	assertion := credentials.ClientAssertion
+	if assertion == "" {
+		client, err := m.store.GetClient(credentials.ClientID)
+		if err != nil {
+			return client, err
+		}
+		if credentials.ClientSecret != "" && credentials.AuthMethod != "" && !client.AcceptsAuthMethod(credentials.AuthMethod) {
+			return client, errors.New(util.InvalidClient)
+		}
+		return client, authenticateClient(client, credentials.ClientSecret)
+	}
+	if credentials.ClientAssertionType != util.ClientAssertionTypeJWT {
+		return model.Clients{}, errors.New(util.UnsupportedAssertion)
+	}
+	issuer, err := assertionIssuer(assertion)
+	if err != nil {
+		return model.Clients{}, errors.New(util.InvalidClient)
+	}
+	if credentials.ClientID != uuid.Nil && credentials.ClientID != issuer {
+		return model.Clients{}, errors.New(util.InvalidClient)
+	}
+	client, err := m.store.GetClient(issuer)
+	if err != nil {
+		return client, err
+	}
+	// clients registered with a secret authentication method may not switch to assertions
+	if !client.AcceptsAuthMethod(util.AuthMethodPrivateKey) {
+		return client, errors.New(util.InvalidClient)
+	}
+	claims, err := m.verifyAssertion(assertion, client)
+	if err != nil {
+		return client, clientAssertionError(err)
+	}
+	// client assertion is about the client itself
+	if claims.Subject != client.ID.String() {
+		return client, errors.New(util.InvalidClient)
+	}
+	return client, clientAssertionError(m.store.UseJTI(client.ID, claims.ID, claims.ExpiresAt+util.ClockSkew))
+}
+
+// clientAssertionError turns error of assertion verification into client authentication error,
+// missing audience and storage failures are kept as they are not the client's fault
+func clientAssertionError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch err.Error() {
+	case util.InvalidAssertion, util.AssertionExpired, util.AssertionReplayed, util.InvalidJWKS:
+		return errors.New(util.InvalidClient)
+	}
+	return err
+}
+
+// prepareAssertionGrant verifies JWT bearer authorization grant (RFC 7523 2.1) and sets client, user and scope of token on info,
+// the assertion is signed by a client and its subject is either the client itself (token without user)
+// or the user who owns the client, scope must be within scopes the client is allowed,
+// client authentication of info is optional but must identify the issuer when present
+func (m *Manager) prepareAssertionGrant(assertion string, info model.TokenInfo) error {
+	issuer, err := assertionIssuer(assertion)
+	if err != nil {
+		return err
+	}
+	credentials := clientCredentials(info)
+	if credentials.ClientID != uuid.Nil || credentials.ClientAssertion != "" {
+		client, err := m.AuthenticateClient(credentials)
+		if err != nil {
+			return err
+		}
+		if client.ID != issuer {
+			return errors.New(util.InvalidClient)
+		}
+	}
+	client, err := m.store.GetClient(issuer)
+	if err != nil {
+		return err
+	}
+	if !client.AllowsGrant(util.GrantJWTBearer) {
+		return errors.New(util.UnauthorizedClient)
+	}
+	claims, err := m.verifyAssertion(assertion, client)
+	if err != nil {
+		return err
+	}
+	var userId int64
+	switch claims.Subject {
+	case client.ID.String():
+	case strconv.FormatInt(client.UserId, 10):
+		userId = client.UserId
+	default:
+		return errors.New(util.InvalidAssertion)
+	}
+	scope, err := clientScope(client, info.GetScope())
+	if err != nil {
+		return err
+	}
+	if err := m.store.UseJTI(client.ID, claims.ID, claims.ExpiresAt+util.ClockSkew); err != nil {
+		return err
+	}
+	info.SetClientID(client.ID)
+	info.SetUserID(userId)
+	info.SetScope(scope)
+	applyClientLifetimes(info, client)
+	return nil
+}
+
+// SetClientJWKS registers public keys client signs its assertions with,
+// jwks JWK Set replacing previously registered keys, see util.NewJWKSet to register single public key
+func (m *Manager) SetClientJWKS(clientId uuid.UUID, jwks util.JWKSet) error {
+	encoded, err := encodeClientJWKS(jwks)
+	if err != nil {
+		return err
+	}
+	return m.updateClient(clientId, func(client *model.Clients) {
+		client.JWKS = encoded
+	})
+}
+
+// CreateAssertionToken issues access token for JWT bearer assertion (RFC 7523 2.1) signed with key registered for its issuer client,
+// subject of the assertion is the client itself or the user owning the client, scope is limited like for client credentials,
+// info may authenticate the client and holds requested scope and token lifetime, no refresh token is issued
+func (m *Manager) CreateAssertionToken(assertion string, info model.TokenInfo) (model.TokenResponse, error) {
+	err := m.prepareAssertionGrant(assertion, info)
+	if err != nil {
+		return model.TokenResponse{}, err
+	}
+	return m.insertAccessToken(info)
+}
@@ -0,0 +1,225 @@
+package golang_oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+const testAudience = "https://auth.example.com/token"
+
+// signAssertion signs assertion issued by client with given key, subject and id
+func signAssertion(t *testing.T, key crypto.Signer, alg, kid string, claims model.AssertionClaims) string {
+	assertion, err := util.SignJWT(claims, alg, kid, key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return assertion
+}
+
+// testAssertion runs private_key_jwt client authentication and JWT bearer grant against given store
+func testAssertion(t *testing.T, store *Manager) {
+	store.SetAssertionAudience(testAudience)
+	client, err := store.CreateClient(userID, "service")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientScope(client.ID, "read write"); err != nil {
+		t.Fatal(err.Error())
+	}
+	ecKey, err := util.GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	jwks, err := util.NewJWKSet(&ecKey.PublicKey, &rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientJWKS(client.ID, jwks); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientJWKS(uuid.New(), jwks); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s, got %v", util.InvalidClient, err)
+	}
+	if err := store.SetClientJWKS(client.ID, util.JWKSet{}); err == nil || err.Error() != util.InvalidJWKS {
+		t.Errorf("expected %s, got %v", util.InvalidJWKS, err)
+	}
+	claims := func(subject string) model.AssertionClaims {
+		return model.AssertionClaims{
+			Issuer:    client.ID.String(),
+			Subject:   subject,
+			Audience:  model.Audience{testAudience},
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			ID:        uuid.New().String(),
+		}
+	}
+
+	t.Run("ClientAssertion", func(t *testing.T) {
+		assertion := signAssertion(t, ecKey, util.ES256, jwks.Keys[0].Kid, claims(client.ID.String()))
+		info := &model.Token{
+			ClientAssertionType: util.ClientAssertionTypeJWT,
+			ClientAssertion:     assertion,
+			AccessCreateAt:      time.Now(),
+			AccessExpiresIn:     time.Minute,
+		}
+		resp, err := store.CreateClientToken(info)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access, err := store.GetByAccess(resp.AccessToken); err != nil || access.ClientId != client.ID {
+			t.Errorf("unexpected access token %+v, %v", access, err)
+		}
+		// replayed client assertion fails client authentication
+		if _, err := store.CreateClientToken(info); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+
+		// key without kid is found among registered keys
+		info.ClientID = client.ID
+		info.UserID = userID
+		info.ClientAssertion = signAssertion(t, rsaKey, util.RS256, "", claims(client.ID.String()))
+		if _, err := store.Create(info); err != nil {
+			t.Error(err.Error())
+		}
+	})
+
+	t.Run("InvalidClientAssertion", func(t *testing.T) {
+		otherKey, err := util.GenerateECKey()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		wrongAudience := claims(client.ID.String())
+		wrongAudience.Audience = model.Audience{"https://other.example.com"}
+		expired := claims(client.ID.String())
+		expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+		tooLong := claims(client.ID.String())
+		tooLong.ExpiresAt = time.Now().Add(time.Hour * 24).Unix()
+		cases := map[string]struct {
+			assertionType string
+			assertion     string
+			err           string
+		}{
+			"UnknownKey":    {util.ClientAssertionTypeJWT, signAssertion(t, otherKey, util.ES256, "", claims(client.ID.String())), util.InvalidClient},
+			"WrongAudience": {util.ClientAssertionTypeJWT, signAssertion(t, ecKey, util.ES256, "", wrongAudience), util.InvalidClient},
+			"WrongSubject":  {util.ClientAssertionTypeJWT, signAssertion(t, ecKey, util.ES256, "", claims("1")), util.InvalidClient},
+			"Expired":       {util.ClientAssertionTypeJWT, signAssertion(t, ecKey, util.ES256, "", expired), util.InvalidClient},
+			"TooLong":       {util.ClientAssertionTypeJWT, signAssertion(t, ecKey, util.ES256, "", tooLong), util.InvalidClient},
+			"Unsupported":   {"urn:ietf:params:oauth:client-assertion-type:saml2-bearer", "assertion", util.UnsupportedAssertion},
+		}
+		for name, item := range cases {
+			_, err := store.AuthenticateClient(model.ClientCredentials{ClientAssertionType: item.assertionType, ClientAssertion: item.assertion})
+			if err == nil || err.Error() != item.err {
+				t.Errorf("%s: expected %s, got %v", name, item.err, err)
+			}
+		}
+		assertion := signAssertion(t, ecKey, util.ES256, "", claims(client.ID.String()))
+		if _, err := store.AuthenticateClient(model.ClientCredentials{ClientID: uuid.New(), ClientAssertionType: util.ClientAssertionTypeJWT, ClientAssertion: assertion}); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+	})
+
+	t.Run("JWTBearer", func(t *testing.T) {
+		assertion := signAssertion(t, ecKey, util.ES256, "", claims("1"))
+		info := &model.Token{Scope: "read", AccessCreateAt: time.Now(), AccessExpiresIn: time.Minute}
+		resp, err := store.CreateAssertionToken(assertion, info)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.RefreshToken != "" {
+			t.Error("refresh token must not be issued for assertion")
+		}
+		access, err := store.GetByAccess(resp.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access.UserId != userID || access.ClientId != client.ID || access.Scope != "read" {
+			t.Errorf("unexpected access token %+v", access)
+		}
+		if _, err := store.CreateAssertionToken(assertion, &model.Token{AccessCreateAt: time.Now()}); err == nil || err.Error() != util.AssertionReplayed {
+			t.Errorf("expected %s, got %v", util.AssertionReplayed, err)
+		}
+
+		// assertion about the client itself issues token without user
+		resp, err = store.CreateAssertionToken(signAssertion(t, rsaKey, util.RS256, "", claims(client.ID.String())),
+			&model.Token{ClientID: client.ID, ClientSecret: client.Secret, AccessCreateAt: time.Now(), AccessExpiresIn: time.Minute})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access, err := store.GetByAccess(resp.AccessToken); err != nil || access.UserId != 0 || access.Scope != "read write" {
+			t.Errorf("unexpected access token %+v, %v", access, err)
+		}
+	})
+
+	t.Run("InvalidJWTBearer", func(t *testing.T) {
+		info := &model.Token{AccessCreateAt: time.Now()}
+		if _, err := store.CreateAssertionToken(signAssertion(t, ecKey, util.ES256, "", claims("2")), info); err == nil || err.Error() != util.InvalidAssertion {
+			t.Errorf("expected %s, got %v", util.InvalidAssertion, err)
+		}
+		info.Scope = "admin"
+		if _, err := store.CreateAssertionToken(signAssertion(t, ecKey, util.ES256, "", claims("1")), info); err == nil || err.Error() != util.InvalidScope {
+			t.Errorf("expected %s, got %v", util.InvalidScope, err)
+		}
+		other, err := store.CreateClient(userID, "other")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		info = &model.Token{ClientID: other.ID, ClientSecret: other.Secret, AccessCreateAt: time.Now()}
+		if _, err := store.CreateAssertionToken(signAssertion(t, ecKey, util.ES256, "", claims("1")), info); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+	})
+}
+
+func TestAssertionWithoutAudience(t *testing.T) {
+	store := NewManager(NewDefaultMemoryStore())
+	defer store.Close()
+	client, err := store.CreateClient(userID, "service")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	key, err := util.GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	jwks, err := util.NewJWKSet(key.Public().(*ecdsa.PublicKey))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientJWKS(client.ID, jwks); err != nil {
+		t.Fatal(err.Error())
+	}
+	assertion := signAssertion(t, key, util.ES256, "", model.AssertionClaims{
+		Issuer:    client.ID.String(),
+		Subject:   client.ID.String(),
+		Audience:  model.Audience{testAudience},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		ID:        uuid.New().String(),
+	})
+	if _, err := store.AuthenticateClient(model.ClientCredentials{ClientID: client.ID, ClientAssertionType: util.ClientAssertionTypeJWT, ClientAssertion: assertion}); err == nil || err.Error() != util.MissingAudience {
+		t.Errorf("expected %s, got %v", util.MissingAudience, err)
+	}
+
+	// empty audience does not count as configured, so empty aud claim is not accepted
+	store.SetAssertionAudience("")
+	assertion = signAssertion(t, key, util.ES256, "", model.AssertionClaims{
+		Issuer:    client.ID.String(),
+		Subject:   client.ID.String(),
+		Audience:  model.Audience{""},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		ID:        uuid.New().String(),
+	})
+	if _, err := store.AuthenticateClient(model.ClientCredentials{ClientID: client.ID, ClientAssertionType: util.ClientAssertionTypeJWT, ClientAssertion: assertion}); err == nil || err.Error() != util.MissingAudience {
+		t.Errorf("expected %s for empty audience, got %v", util.MissingAudience, err)
+	}
+}
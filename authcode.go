@@ -0,0 +1,203 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// newAuthCodeClient builds client allowed to use authorization code grant,
+// confidential clients get a secret, public clients (SPAs, mobile apps) get none and must use PKCE,
+// returned client carries hash of the secret, plain text secret is returned separately
+func newAuthCodeClient(userId int64, name string, redirectURIs []string, confidential bool) (model.Clients, string, error) {
+	client := model.Clients{}
+	if userId == 0 {
+		return client, "", errors.New(util.EmptyUserID)
+	}
+	if err := validateRedirectURIs(redirectURIs); err != nil {
+		return client, "", err
+	}
+	client.ID = uuid.New()
+	client.Name = name
+	secret := ""
+	if confidential {
+		var err error
+		if secret, err = newClientSecret(&client); err != nil {
+			return client, "", err
+		}
+	}
+	client.Redirect = strings.Join(redirectURIs, " ")
+	client.Public = !confidential
+	client.UserId = userId
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = time.Now()
+	return client, secret, nil
+}
+
+// validateRedirectURIs checks that at least one redirect uri is given and none of them is empty,
+// contains whitespace which separates stored uris or a fragment (RFC 6749 3.1.2)
+func validateRedirectURIs(redirectURIs []string) error {
+	if len(redirectURIs) == 0 {
+		return errors.New(util.InvalidRedirectURI)
+	}
+	for _, uri := range redirectURIs {
+		if uri == "" || strings.ContainsAny(uri, " \t\n#") {
+			return errors.New(util.InvalidRedirectURI)
+		}
+	}
+	return nil
+}
+
+// newAuthCode validates authorization request against client and builds authorization code,
+// returned model is not persisted, it is up to Manager to save it in its store
+func newAuthCode(client model.Clients, request model.AuthCodeRequest) (*model.AuthCodes, error) {
+	if request.UserID == 0 {
+		return nil, errors.New(util.EmptyUserID)
+	}
+	if client.ID == uuid.Nil || client.Revoked {
+		return nil, errors.New(util.InvalidClient)
+	}
+	if !client.AllowsGrant(util.GrantAuthorizationCode) {
+		return nil, errors.New(util.UnauthorizedClient)
+	}
+	if !client.HasRedirect(request.RedirectURI) {
+		return nil, errors.New(util.InvalidRedirectURI)
+	}
+	if request.CodeChallenge != "" {
+		if err := util.ValidateCodeChallenge(request.CodeChallenge, request.CodeChallengeMethod); err != nil {
+			return nil, err
+		}
+	} else if client.Public {
+		return nil, errors.New(util.MissingCodeChallenge)
+	}
+	method := request.CodeChallengeMethod
+	if request.CodeChallenge != "" && method == "" {
+		method = util.PKCEPlain
+	}
+	authTime := request.AuthTime
+	if authTime.IsZero() {
+		authTime = time.Now()
+	}
+	expiresIn := request.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = time.Second * util.AuthCodeExpiry
+	}
+	return &model.AuthCodes{
+		Model: model.Model{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		UserId:              request.UserID,
+		ClientId:            client.ID,
+		RedirectURI:         request.RedirectURI,
+		Scope:               request.Scope,
+		CodeChallenge:       request.CodeChallenge,
+		CodeChallengeMethod: method,
+		Nonce:               request.Nonce,
+		AuthTime:            authTime.Unix(),
+		ExpiredAt:           time.Now().Add(expiresIn).Unix(),
+	}, nil
+}
+
+// verifyAuthCode checks that authorization code may be exchanged by already authenticated client presenting info and codeVerifier,
+// code must be unused, unexpired and bound to the same client, redirect uri and code challenge
+func verifyAuthCode(code model.AuthCodes, client model.Clients, info model.TokenInfo, codeVerifier string) error {
+	if code.Revoked {
+		return errors.New(util.AuthCodeUsed)
+	}
+	if code.ExpiredAt < time.Now().Unix() {
+		return errors.New(util.AuthCodeExpired)
+	}
+	if code.ClientId != client.ID {
+		return errors.New(util.InvalidClient)
+	}
+	if code.RedirectURI != info.GetRedirectURI() {
+		return errors.New(util.InvalidRedirectURI)
+	}
+	if code.CodeChallenge == "" {
+		// verifier without challenge means the challenge was stripped from authorization request
+		if codeVerifier != "" {
+			return errors.New(util.InvalidCodeVerifier)
+		}
+		return nil
+	}
+	return util.VerifyCodeVerifier(codeVerifier, code.CodeChallenge, code.CodeChallengeMethod)
+}
+
+// CreateAuthCodeClient creates new client allowed to use authorization code grant,
+// userId user's id who created the client, redirectURIs uris codes may be sent to,
+// confidential false creates public client without secret which must use PKCE
+func (m *Manager) CreateAuthCodeClient(userId int64, name string, redirectURIs []string, confidential bool) (model.Clients, error) {
+	client, secret, err := newAuthCodeClient(userId, name, redirectURIs, confidential)
+	if err != nil {
+		return client, err
+	}
+	err = m.store.InsertClient(client)
+	if err != nil {
+		return client, err
+	}
+	client.Secret = secret
+	return client, nil
+}
+
+// CreateAuthCode creates one time authorization code for authorization request approved by resource owner,
+// redirect uri must be registered for the client, public clients must send PKCE code challenge
+func (m *Manager) CreateAuthCode(request model.AuthCodeRequest) (string, error) {
+	client, err := m.store.GetClient(request.ClientID)
+	if err != nil {
+		return "", err
+	}
+	authCode, err := newAuthCode(client, request)
+	if err != nil {
+		return "", err
+	}
+	err = m.store.InsertAuthCode(*authCode)
+	if err != nil {
+		return "", err
+	}
+	return m.encodeAuthCode(authCode.ID)
+}
+
+// ExchangeAuthCode exchanges authorization code for access token and refresh token,
+// code authorization code, codeVerifier PKCE code verifier (empty when code was issued without challenge),
+// info holds client credentials, redirect uri and token lifetimes, user and scope of the code are set on it
+func (m *Manager) ExchangeAuthCode(code, codeVerifier string, info model.TokenInfo) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	payload, err := m.decodeAuthCode(code)
+	if err != nil {
+		return tokenResp, err
+	}
+	authCode, err := m.store.GetAuthCode(payload.AuthCodeId)
+	if err != nil {
+		return tokenResp, err
+	}
+	client, err := m.authenticateInfo(info, util.GrantAuthorizationCode)
+	if err != nil {
+		return tokenResp, err
+	}
+	err = verifyAuthCode(authCode, client, info, codeVerifier)
+	if err != nil {
+		return tokenResp, err
+	}
+
+	// codes are one time use, only the request which flips revoked may issue tokens
+	used, err := m.store.UseAuthCode(authCode.ID)
+	if err != nil {
+		return tokenResp, err
+	}
+	if !used {
+		return tokenResp, errors.New(util.AuthCodeUsed)
+	}
+
+	info.SetUserID(authCode.UserId)
+	info.SetScope(authCode.Scope)
+	tokenResp, err = m.insertTokens(info, uuid.Nil)
+	if err != nil {
+		return tokenResp, err
+	}
+	return m.withIDToken(tokenResp, authCode, client)
+}
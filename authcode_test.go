@@ -0,0 +1,206 @@
+package golang_oauth
+
+import (
+	"database/sql"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	testRedirectURI  = "https://app.example.com/callback"
+	testCodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeS256     = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// testAuthCode runs authorization code grant with and without PKCE against given store
+func testAuthCode(t *testing.T, store *Manager) {
+	public, err := store.CreateAuthCodeClient(userID, "spa", []string{testRedirectURI}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if public.Secret != "" || !public.Public {
+		t.Errorf("public client must not have secret")
+	}
+	confidential, err := store.CreateAuthCodeClient(userID, "web app", []string{"https://web.example.com/cb", testRedirectURI}, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if client, err := store.GetClient(confidential.ID); err != nil || !client.HasRedirect(testRedirectURI) || client.Public {
+		t.Fatalf("unexpected client %+v, %v", client, err)
+	}
+
+	request := model.AuthCodeRequest{
+		ClientID:            public.ID,
+		UserID:              userID,
+		RedirectURI:         testRedirectURI,
+		Scope:               "post",
+		CodeChallenge:       testCodeS256,
+		CodeChallengeMethod: util.PKCES256,
+	}
+	exchange := func(client model.Clients, code, verifier, redirectURI string) (model.TokenResponse, error) {
+		return store.ExchangeAuthCode(code, verifier, &model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			RedirectURI:     redirectURI,
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+		})
+	}
+
+	t.Run("PKCE", func(t *testing.T) {
+		code, err := store.CreateAuthCode(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := exchange(public, code, testCodeVerifier[1:]+"x", testRedirectURI); err == nil || err.Error() != util.InvalidCodeVerifier {
+			t.Errorf("expected %s, got %v", util.InvalidCodeVerifier, err)
+		}
+		if _, err := exchange(public, code, testCodeVerifier, "https://evil.example.com/callback"); err == nil || err.Error() != util.InvalidRedirectURI {
+			t.Errorf("expected %s, got %v", util.InvalidRedirectURI, err)
+		}
+		if _, err := exchange(confidential, code, testCodeVerifier, testRedirectURI); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+		resp, err := exchange(public, code, testCodeVerifier, testRedirectURI)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		access, err := store.GetByAccess(resp.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access.UserId != userID || access.Scope != "post" || access.ClientId != public.ID {
+			t.Errorf("unexpected access token %+v", access)
+		}
+		if _, err := exchange(public, code, testCodeVerifier, testRedirectURI); err == nil || err.Error() != util.AuthCodeUsed {
+			t.Errorf("expected %s, got %v", util.AuthCodeUsed, err)
+		}
+	})
+
+	t.Run("Plain", func(t *testing.T) {
+		plain := request
+		plain.CodeChallenge = testCodeVerifier
+		plain.CodeChallengeMethod = ""
+		code, err := store.CreateAuthCode(plain)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := exchange(public, code, testCodeVerifier, testRedirectURI); err != nil {
+			t.Error(err.Error())
+		}
+	})
+
+	t.Run("Confidential", func(t *testing.T) {
+		withoutPKCE := request
+		withoutPKCE.ClientID = confidential.ID
+		withoutPKCE.CodeChallenge = ""
+		withoutPKCE.CodeChallengeMethod = ""
+		code, err := store.CreateAuthCode(withoutPKCE)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		wrongSecret := confidential
+		wrongSecret.Secret = "wrong"
+		if _, err := exchange(wrongSecret, code, "", testRedirectURI); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+		if _, err := exchange(confidential, code, testCodeVerifier, testRedirectURI); err == nil || err.Error() != util.InvalidCodeVerifier {
+			t.Errorf("expected %s, got %v", util.InvalidCodeVerifier, err)
+		}
+		if _, err := exchange(confidential, code, "", testRedirectURI); err != nil {
+			t.Error(err.Error())
+		}
+	})
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		unregistered := request
+		unregistered.RedirectURI = "https://evil.example.com/callback"
+		if _, err := store.CreateAuthCode(unregistered); err == nil || err.Error() != util.InvalidRedirectURI {
+			t.Errorf("expected %s, got %v", util.InvalidRedirectURI, err)
+		}
+		withoutPKCE := request
+		withoutPKCE.CodeChallenge = ""
+		if _, err := store.CreateAuthCode(withoutPKCE); err == nil || err.Error() != util.MissingCodeChallenge {
+			t.Errorf("expected %s, got %v", util.MissingCodeChallenge, err)
+		}
+		if _, err := exchange(public, "forged", testCodeVerifier, testRedirectURI); err == nil || err.Error() != util.InvalidAuthCode {
+			t.Errorf("expected %s, got %v", util.InvalidAuthCode, err)
+		}
+	})
+}
+
+func TestAuthCodeExpired(t *testing.T) {
+	store := NewManager(NewDefaultMemoryStore())
+	defer store.Close()
+	client, err := store.CreateAuthCodeClient(userID, "spa", []string{testRedirectURI}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	code, err := store.CreateAuthCode(model.AuthCodeRequest{
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   testRedirectURI,
+		CodeChallenge: testCodeVerifier,
+		ExpiresIn:     time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(time.Second)
+	_, err = store.ExchangeAuthCode(code, testCodeVerifier, &model.Token{ClientID: client.ID, RedirectURI: testRedirectURI})
+	if err == nil || err.Error() != util.AuthCodeExpired {
+		t.Errorf("expected %s, got %v", util.AuthCodeExpired, err)
+	}
+}
+
+func TestMigrateClientTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golang-oauth")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "oauth.db")
+
+	// clients table as created by earlier versions of the store
+	db, err := sql.Open(util.DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = db.Exec("CREATE TABLE oauth_clients (id varchar(255) not null primary key, created_at datetime, updated_at datetime, " +
+		"user_id integer, name varchar(255), secret varchar(255), revoked integer)")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = db.Exec("INSERT INTO oauth_clients VALUES ('6ba7b810-9dad-11d1-80b4-00c04fd430c8', ?, ?, 1, 'old app', 'secret', 0)", time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_ = db.Close()
+
+	backend := NewDefaultStore(NewSQLiteConfig(path))
+	store := NewManager(backend)
+	defer store.Close()
+	clients, err := backend.db.Select(model.Clients{}, "SELECT * FROM oauth_clients")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(clients) != 1 || clients[0].(*model.Clients).Public || clients[0].(*model.Clients).Redirect != "" {
+		t.Errorf("unexpected migrated clients %+v", clients)
+	}
+	// plain text secret is replaced by its hash issued when the client was created and still authenticates the client
+	if len(clients) == 1 && (!util.IsSecretHash(clients[0].(*model.Clients).Secret) || clients[0].(*model.Clients).SecretCreatedAt == 0) {
+		t.Errorf("expected hashed secret with issue time, got %+v", clients[0])
+	}
+	if _, err := store.AuthenticateClient(model.ClientCredentials{ClientID: uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"), ClientSecret: "secret"}); err != nil {
+		t.Errorf("migrated client must authenticate with its secret, got %v", err)
+	}
+	if _, err := store.CreateAuthCodeClient(userID, "new app", []string{testRedirectURI}, true); err != nil {
+		t.Error(err.Error())
+	}
+}
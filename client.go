@@ -0,0 +1,191 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// authenticateClient authenticates client presenting given secret,
+// public clients have no secret and are identified by client id only
+func authenticateClient(client model.Clients, secret string) error {
+	if client.ID == uuid.Nil || client.Revoked {
+		return errors.New(util.InvalidClient)
+	}
+	if client.Public {
+		return nil
+	}
+	// clients authenticating with private_key_jwt have no secret to match an empty one
+	if client.Secret == "" || secret == "" {
+		return errors.New(util.InvalidClient)
+	}
+	if util.VerifySecret(client.Secret, secret) {
+		return nil
+	}
+	// secret replaced by rotation is accepted until its grace deadline
+	if client.PreviousSecret != "" && time.Now().Unix() < client.PreviousExpiredAt &&
+		util.VerifySecret(client.PreviousSecret, secret) {
+		return nil
+	}
+	return errors.New(util.InvalidClient)
+}
+
+// newClientSecret generates random secret for client and sets its hash on client,
+// the returned plain text secret is shown to the client once and never stored
+func newClientSecret(client *model.Clients) (string, error) {
+	secret, err := util.RandomToken(util.ClientSecretSize)
+	if err != nil {
+		return "", err
+	}
+	hash, err := util.HashSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	client.Secret = hash
+	client.SecretCreatedAt = time.Now().Unix()
+	return secret, nil
+}
+
+// rotateClientSecret issues new secret to client and keeps the current one valid for grace,
+// secret still in grace of earlier rotation stops being accepted, zero grace invalidates current secret at once
+func rotateClientSecret(client *model.Clients, grace time.Duration) (string, error) {
+	if client.Public || client.Secret == "" {
+		return "", errors.New(util.ClientWithoutSecret)
+	}
+	previous, previousCreatedAt := client.Secret, client.SecretCreatedAt
+	secret, err := newClientSecret(client)
+	if err != nil {
+		return "", err
+	}
+	clearPreviousSecret(client)
+	if grace > 0 {
+		client.PreviousSecret = previous
+		client.PreviousCreatedAt = previousCreatedAt
+		client.PreviousExpiredAt = time.Now().Add(grace).Unix()
+	}
+	client.UpdatedAt = time.Now()
+	return secret, nil
+}
+
+// clearPreviousSecret ends secret rotation of client, only its current secret is accepted afterwards
+func clearPreviousSecret(client *model.Clients) {
+	client.PreviousSecret = ""
+	client.PreviousCreatedAt = 0
+	client.PreviousExpiredAt = 0
+}
+
+// applyClientLifetimes fills token creation times and lifetimes info leaves empty,
+// lifetimes default to those configured for client, then to util.AccessTokenExpiry and util.RefreshTokenExpiry
+func applyClientLifetimes(info model.TokenInfo, client model.Clients) {
+	if info.GetAccessCreateAt().IsZero() {
+		info.SetAccessCreateAt(time.Now())
+	}
+	if info.GetAccessExpiresIn() <= 0 {
+		lifetime := client.AccessTokenLifetime
+		if lifetime <= 0 {
+			lifetime = util.AccessTokenExpiry
+		}
+		info.SetAccessExpiresIn(time.Second * time.Duration(lifetime))
+	}
+	if info.GetRefreshCreateAt().IsZero() {
+		info.SetRefreshCreateAt(time.Now())
+	}
+	if info.GetRefreshExpiresIn() <= 0 {
+		lifetime := client.RefreshTokenLifetime
+		if lifetime <= 0 {
+			lifetime = util.RefreshTokenExpiry
+		}
+		info.SetRefreshExpiresIn(time.Second * time.Duration(lifetime))
+	}
+}
+
+// CreateClient creates new client,
+// userId user's id who created the client, returned client carries plain text secret which is stored hashed
+func (m *Manager) CreateClient(userId int64, name string) (model.Clients, error) {
+	client := model.Clients{}
+	if userId == 0 {
+		return client, errors.New(util.EmptyUserID)
+	}
+	client.ID = uuid.New()
+	client.Name = name
+	secret, err := newClientSecret(&client)
+	if err != nil {
+		return client, err
+	}
+	client.UserId = userId
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = time.Now()
+	err = m.store.InsertClient(client)
+	if err != nil {
+		return client, err
+	}
+	client.Secret = secret
+	return client, nil
+}
+
+// GetClient returns client of given id
+func (m *Manager) GetClient(clientId uuid.UUID) (model.Clients, error) {
+	return m.store.GetClient(clientId)
+}
+
+// updateClient applies change to client of given id and saves it
+func (m *Manager) updateClient(clientId uuid.UUID, change func(client *model.Clients)) error {
+	client, err := m.store.GetClient(clientId)
+	if err != nil {
+		return err
+	}
+	change(&client)
+	client.UpdatedAt = time.Now()
+	return m.store.UpdateClient(client)
+}
+
+// SetClientScope sets scopes client may request for tokens issued to itself,
+// scope space separated scopes, "*" allows any scope
+func (m *Manager) SetClientScope(clientId uuid.UUID, scope string) error {
+	return m.updateClient(clientId, func(client *model.Clients) {
+		client.Scope = strings.Join(strings.Fields(scope), " ")
+	})
+}
+
+// SetClientTokenLifetime sets default lifetimes of tokens issued to client when the request does not set them,
+// zero uses util.AccessTokenExpiry and util.RefreshTokenExpiry
+func (m *Manager) SetClientTokenLifetime(clientId uuid.UUID, access, refresh time.Duration) error {
+	return m.updateClient(clientId, func(client *model.Clients) {
+		client.AccessTokenLifetime = int64(access / time.Second)
+		client.RefreshTokenLifetime = int64(refresh / time.Second)
+	})
+}
+
+// RotateClientSecret issues new secret to client and keeps accepting the current one until grace passes,
+// returns new plain text secret which is stored hashed, secret still in grace of earlier rotation stops being accepted
+func (m *Manager) RotateClientSecret(clientId uuid.UUID, grace time.Duration) (string, error) {
+	client, err := m.store.GetClient(clientId)
+	if err != nil {
+		return "", err
+	}
+	current := client.Secret
+	secret, err := rotateClientSecret(&client, grace)
+	if err != nil {
+		return "", err
+	}
+	// guarded by current secret so concurrent rotations do not both succeed
+	if err := m.store.UpdateClientSecret(client, current); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// FinishSecretRotation stops accepting secret replaced by rotation before its grace deadline,
+// once all deployments use the new secret
+func (m *Manager) FinishSecretRotation(clientId uuid.UUID) error {
+	client, err := m.store.GetClient(clientId)
+	if err != nil {
+		return err
+	}
+	clearPreviousSecret(&client)
+	client.UpdatedAt = time.Now()
+	return m.store.UpdateClientSecret(client, client.Secret)
+}
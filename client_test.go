@@ -0,0 +1,113 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// testSecretRotation runs client secret rotation against given store
+func testSecretRotation(t *testing.T, store *Manager) {
+	client, err := store.CreateClient(userID, "rotating app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	first := client.Secret
+	authenticate := func(secret string) error {
+		_, err := store.AuthenticateClient(model.ClientCredentials{ClientID: client.ID, ClientSecret: secret})
+		return err
+	}
+
+	second, err := store.RotateClientSecret(client.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if second == "" || second == first {
+		t.Fatal("rotation must issue new secret")
+	}
+	if authenticate(first) != nil || authenticate(second) != nil {
+		t.Error("both secrets must be accepted during grace")
+	}
+	rotated, err := store.GetClient(client.ID)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !util.IsSecretHash(rotated.PreviousSecret) || rotated.SecretCreatedAt == 0 ||
+		rotated.PreviousCreatedAt != client.SecretCreatedAt || rotated.PreviousExpiredAt <= time.Now().Unix() {
+		t.Errorf("unexpected rotated client %+v", rotated)
+	}
+
+	// rotating again replaces the secret still in grace
+	third, err := store.RotateClientSecret(client.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := authenticate(first); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s for secret replaced twice, got %v", util.InvalidClient, err)
+	}
+	if authenticate(second) != nil || authenticate(third) != nil {
+		t.Error("current and previous secret must be accepted during grace")
+	}
+
+	if err := store.FinishSecretRotation(client.ID); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := authenticate(second); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s after rotation finished, got %v", util.InvalidClient, err)
+	}
+	if err := authenticate(third); err != nil {
+		t.Error(err.Error())
+	}
+	if finished, err := store.GetClient(client.ID); err != nil || finished.PreviousSecret != "" || finished.PreviousExpiredAt != 0 {
+		t.Errorf("expected no previous secret, got %+v %v", finished, err)
+	}
+
+	// without grace the current secret stops being accepted at once
+	fourth, err := store.RotateClientSecret(client.ID, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := authenticate(third); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s without grace, got %v", util.InvalidClient, err)
+	}
+	if err := authenticate(fourth); err != nil {
+		t.Error(err.Error())
+	}
+
+	public, err := store.CreateAuthCodeClient(userID, "public app", []string{testRedirectURI}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.RotateClientSecret(public.ID, time.Hour); err == nil || err.Error() != util.ClientWithoutSecret {
+		t.Errorf("expected %s, got %v", util.ClientWithoutSecret, err)
+	}
+	if _, err := store.RotateClientSecret(uuid.New(), time.Hour); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s, got %v", util.InvalidClient, err)
+	}
+	if err := store.FinishSecretRotation(uuid.New()); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s, got %v", util.InvalidClient, err)
+	}
+}
+
+func TestAuthenticateClientPreviousSecret(t *testing.T) {
+	client := model.Clients{}
+	client.ID = uuid.New()
+	if _, err := newClientSecret(&client); err != nil {
+		t.Fatal(err.Error())
+	}
+	previous, err := util.HashSecret("previous")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	client.PreviousSecret = previous
+	client.PreviousExpiredAt = time.Now().Add(time.Minute).Unix()
+	if err := authenticateClient(client, "previous"); err != nil {
+		t.Error(err.Error())
+	}
+	client.PreviousExpiredAt = time.Now().Add(-time.Second).Unix()
+	if err := authenticateClient(client, "previous"); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s after grace deadline, got %v", util.InvalidClient, err)
+	}
+}
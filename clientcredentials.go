@@ -0,0 +1,73 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"strings"
+)
+
+// requireConfidential checks that authenticated client may get tokens on its own behalf,
+// public clients cannot as they cannot keep a secret
+func requireConfidential(client model.Clients) error {
+	if client.ID == uuid.Nil || client.Public {
+		return errors.New(util.UnauthorizedClient)
+	}
+	return nil
+}
+
+// clientScope resolves scope of token issued to client itself, requested scope must be within scopes the client is allowed,
+// empty requested scope defaults to every allowed scope, allowed scope "*" allows any scope but is never granted itself
+func clientScope(client model.Clients, requested string) (string, error) {
+	allowed := strings.Fields(client.Scope)
+	if requested == "" {
+		var granted []string
+		for _, scope := range allowed {
+			if scope != "*" {
+				granted = append(granted, scope)
+			}
+		}
+		return strings.Join(granted, " "), nil
+	}
+	for _, scope := range allowed {
+		if scope == "*" {
+			return requested, nil
+		}
+	}
+	for _, scope := range strings.Fields(requested) {
+		found := false
+		for _, item := range allowed {
+			if item == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", errors.New(util.InvalidScope)
+		}
+	}
+	return requested, nil
+}
+
+// CreateClientToken issues access token to the client itself (client credentials grant),
+// info holds client credentials, requested scope and token lifetime, user id is ignored,
+// no refresh token is issued as the client can always request a new token
+func (m *Manager) CreateClientToken(info model.TokenInfo) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	client, err := m.authenticateInfo(info, util.GrantClientCredentials)
+	if err != nil {
+		return tokenResp, err
+	}
+	err = requireConfidential(client)
+	if err != nil {
+		return tokenResp, err
+	}
+	scope, err := clientScope(client, info.GetScope())
+	if err != nil {
+		return tokenResp, err
+	}
+	info.SetUserID(0)
+	info.SetScope(scope)
+	return m.insertAccessToken(info)
+}
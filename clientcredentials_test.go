@@ -0,0 +1,93 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// testClientCredentials runs client credentials grant against given store
+func testClientCredentials(t *testing.T, store *Manager) {
+	client, err := store.CreateClient(userID, "backend job")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientScope(client.ID, "read  write"); err != nil {
+		t.Fatal(err.Error())
+	}
+	other, err := store.CreateClient(userID, "other job")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientScope(other.ID, "*"); err != nil {
+		t.Fatal(err.Error())
+	}
+	createdAt := time.Now()
+	token := func(client model.Clients, scope string) (model.TokenResponse, error) {
+		return store.CreateClientToken(&model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			UserID:          userID,
+			Scope:           scope,
+			AccessCreateAt:  createdAt,
+			AccessExpiresIn: time.Minute,
+		})
+	}
+
+	resp, err := token(client, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if resp.RefreshToken != "" {
+		t.Error("client credentials grant must not issue refresh token")
+	}
+	// token of other client expiring at the same time must not be confused with it
+	otherResp, err := token(other, "admin")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	access, err := store.GetByAccess(resp.AccessToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if access.UserId != 0 || access.ClientId != client.ID || access.Scope != "read write" {
+		t.Errorf("unexpected access token %+v", access)
+	}
+	access, err = store.GetByAccess(otherResp.AccessToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if access.UserId != 0 || access.ClientId != other.ID || access.Scope != "admin" {
+		t.Errorf("unexpected access token %+v", access)
+	}
+
+	if resp, err = token(client, "read"); err != nil {
+		t.Error(err.Error())
+	} else if access, err := store.GetByAccess(resp.AccessToken); err != nil || access.Scope != "read" {
+		t.Errorf("unexpected access token %+v, %v", access, err)
+	}
+	// wildcard is a policy, it is not granted as scope
+	if resp, err := token(other, ""); err != nil || resp.Scope != "" {
+		t.Errorf("expected empty scope, got %+v, %v", resp, err)
+	}
+	if _, err := token(client, "read admin"); err == nil || err.Error() != util.InvalidScope {
+		t.Errorf("expected %s, got %v", util.InvalidScope, err)
+	}
+	wrongSecret := client
+	wrongSecret.Secret = "wrong"
+	if _, err := token(wrongSecret, ""); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s, got %v", util.InvalidClient, err)
+	}
+	public, err := store.CreateAuthCodeClient(userID, "spa", []string{testRedirectURI}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := token(public, ""); err == nil || err.Error() != util.UnauthorizedClient {
+		t.Errorf("expected %s, got %v", util.UnauthorizedClient, err)
+	}
+	if err := store.SetClientScope(uuid.New(), "read"); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s, got %v", util.InvalidClient, err)
+	}
+}
@@ -0,0 +1,201 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"net/url"
+	"time"
+)
+
+// newDeviceCode builds pending device authorization with random user code for already authenticated client,
+// returned model is not persisted, it is up to Manager to save it in its store
+func newDeviceCode(client model.Clients, request model.DeviceCodeRequest) (*model.DeviceCodes, error) {
+	if !client.AllowsGrant(util.GrantDeviceCode) {
+		return nil, errors.New(util.UnauthorizedClient)
+	}
+	userCode, err := util.RandomUserCode(util.UserCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	expiresIn := request.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = time.Second * util.DeviceCodeExpiry
+	}
+	interval := int64(request.Interval / time.Second)
+	if interval <= 0 {
+		interval = util.DeviceCodeInterval
+	}
+	return &model.DeviceCodes{
+		Model: model.Model{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		ClientId:     client.ID,
+		UserCode:     userCode,
+		Scope:        request.Scope,
+		PollInterval: interval,
+		ExpiredAt:    time.Now().Add(expiresIn).Unix(),
+	}, nil
+}
+
+// deviceCodeResponse builds device authorization response for persisted device authorization
+func deviceCodeResponse(deviceCode string, code *model.DeviceCodes, verificationURI string) model.DeviceCodeResponse {
+	resp := model.DeviceCodeResponse{
+		DeviceCode:      deviceCode,
+		UserCode:        util.FormatUserCode(code.UserCode),
+		VerificationURI: verificationURI,
+		ExpiresIn:       code.ExpiredAt - time.Now().Unix(),
+		Interval:        code.PollInterval,
+	}
+	if complete, err := url.Parse(verificationURI); err == nil && verificationURI != "" {
+		query := complete.Query()
+		query.Set("user_code", resp.UserCode)
+		complete.RawQuery = query.Encode()
+		resp.VerificationURIComplete = complete.String()
+	}
+	return resp
+}
+
+// pendingDeviceCode reports whether device authorization still waits for the user to approve or deny it
+func pendingDeviceCode(code model.DeviceCodes) bool {
+	return !code.Approved && !code.Denied && !code.Revoked && code.ExpiredAt >= time.Now().Unix()
+}
+
+// pollDeviceCode checks device authorization polled by already authenticated client,
+// nil means tokens may be issued, otherwise error is util.AuthorizationPending, util.SlowDown,
+// util.ExpiredToken, util.AccessDenied or invalid client/code error,
+// poll time and interval of code are updated and must be persisted by caller
+func pollDeviceCode(code *model.DeviceCodes, client model.Clients) error {
+	if code.ClientId != client.ID {
+		return errors.New(util.InvalidClient)
+	}
+	if code.Revoked {
+		return errors.New(util.InvalidDeviceCode)
+	}
+	now := time.Now().Unix()
+	if code.ExpiredAt < now {
+		return errors.New(util.ExpiredToken)
+	}
+	if code.Denied {
+		return errors.New(util.AccessDenied)
+	}
+	if code.Approved {
+		return nil
+	}
+	// polling faster than the interval increases it by 5 seconds for all subsequent requests (RFC 8628 3.5)
+	tooFast := code.LastPolledAt != 0 && now-code.LastPolledAt < code.PollInterval
+	code.LastPolledAt = now
+	code.UpdatedAt = time.Now()
+	if tooFast {
+		code.PollInterval += 5
+		return errors.New(util.SlowDown)
+	}
+	return errors.New(util.AuthorizationPending)
+}
+
+// CreateDeviceCode authenticates client and creates pending device authorization (RFC 8628 3.1),
+// response holds device code polled by the client and user code the user enters at verification uri
+func (m *Manager) CreateDeviceCode(request model.DeviceCodeRequest) (model.DeviceCodeResponse, error) {
+	client, err := m.AuthenticateClient(model.ClientCredentials{
+		ClientID:            request.ClientID,
+		ClientSecret:        request.ClientSecret,
+		ClientAssertionType: request.ClientAssertionType,
+		ClientAssertion:     request.ClientAssertion,
+		AuthMethod:          request.AuthMethod,
+	})
+	if err != nil {
+		return model.DeviceCodeResponse{}, err
+	}
+	deviceCode, err := newDeviceCode(client, request)
+	if err != nil {
+		return model.DeviceCodeResponse{}, err
+	}
+	// user codes are unique, retry with new one on the rare collision
+	for i := 0; ; i++ {
+		err = m.store.InsertDeviceCode(*deviceCode)
+		if err == nil || i == 2 {
+			break
+		}
+		if deviceCode.UserCode, err = util.RandomUserCode(util.UserCodeLength); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		return model.DeviceCodeResponse{}, err
+	}
+	code, err := m.encodeDeviceCode(deviceCode.ID)
+	if err != nil {
+		return model.DeviceCodeResponse{}, err
+	}
+	return deviceCodeResponse(code, deviceCode, request.VerificationURI), nil
+}
+
+// GetDeviceCode returns pending device authorization of given user code, used to show client and scope to the user
+func (m *Manager) GetDeviceCode(userCode string) (model.DeviceCodes, error) {
+	deviceCode, err := m.store.GetDeviceCodeByUserCode(util.NormalizeUserCode(userCode))
+	if err != nil {
+		return deviceCode, err
+	}
+	if !pendingDeviceCode(deviceCode) {
+		return deviceCode, errors.New(util.InvalidUserCode)
+	}
+	return deviceCode, nil
+}
+
+// ApproveDeviceCode approves pending device authorization of given user code,
+// userId id of logged in user tokens are issued for
+func (m *Manager) ApproveDeviceCode(userCode string, userId int64) error {
+	if userId == 0 {
+		return errors.New(util.EmptyUserID)
+	}
+	return m.store.ApproveDeviceCode(util.NormalizeUserCode(userCode), userId)
+}
+
+// DenyDeviceCode denies pending device authorization of given user code, polling client gets util.AccessDenied
+func (m *Manager) DenyDeviceCode(userCode string) error {
+	return m.store.DenyDeviceCode(util.NormalizeUserCode(userCode))
+}
+
+// PollDeviceCode issues access token and refresh token once device authorization was approved,
+// deviceCode device code returned by CreateDeviceCode, info holds client credentials and token lifetimes,
+// user and scope of the authorization are set on it, until approval util.AuthorizationPending or util.SlowDown is returned
+func (m *Manager) PollDeviceCode(deviceCode string, info model.TokenInfo) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	client, err := m.authenticateInfo(info, util.GrantDeviceCode)
+	if err != nil {
+		return tokenResp, err
+	}
+	payload, err := m.decodeDeviceCode(deviceCode)
+	if err != nil {
+		return tokenResp, err
+	}
+	code, err := m.store.GetDeviceCode(payload.DeviceCodeId)
+	if err != nil {
+		return tokenResp, err
+	}
+	err = pollDeviceCode(&code, client)
+	if err != nil {
+		if err.Error() == util.AuthorizationPending || err.Error() == util.SlowDown {
+			if updateErr := m.store.UpdateDeviceCodePoll(code); updateErr != nil {
+				return tokenResp, updateErr
+			}
+		}
+		return tokenResp, err
+	}
+
+	// device codes are one time use, only the request which flips revoked may issue tokens
+	used, err := m.store.UseDeviceCode(code.ID)
+	if err != nil {
+		return tokenResp, err
+	}
+	if !used {
+		return tokenResp, errors.New(util.InvalidDeviceCode)
+	}
+
+	info.SetUserID(code.UserId)
+	info.SetScope(code.Scope)
+	return m.insertTokens(info, uuid.Nil)
+}
@@ -0,0 +1,147 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDeviceCode runs device authorization grant against given store
+func testDeviceCode(t *testing.T, store *Manager) {
+	client, err := store.CreateClient(userID, "cli")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other, err := store.CreateClient(userID, "other cli")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	poll := func(client model.Clients, deviceCode string) (model.TokenResponse, error) {
+		return store.PollDeviceCode(deviceCode, &model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+		})
+	}
+	request := model.DeviceCodeRequest{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		Scope:           "post",
+		VerificationURI: "https://example.com/device",
+	}
+
+	t.Run("Approve", func(t *testing.T) {
+		resp, err := store.CreateDeviceCode(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(resp.UserCode) != util.UserCodeLength+1 || resp.Interval != util.DeviceCodeInterval || resp.ExpiresIn < util.DeviceCodeExpiry-1 {
+			t.Errorf("unexpected response %+v", resp)
+		}
+		if !strings.HasPrefix(resp.VerificationURIComplete, request.VerificationURI+"?user_code=") {
+			t.Errorf("unexpected verification_uri_complete %s", resp.VerificationURIComplete)
+		}
+
+		if _, err := poll(client, resp.DeviceCode); err == nil || err.Error() != util.AuthorizationPending {
+			t.Errorf("expected %s, got %v", util.AuthorizationPending, err)
+		}
+		if _, err := poll(client, resp.DeviceCode); err == nil || err.Error() != util.SlowDown {
+			t.Errorf("expected %s, got %v", util.SlowDown, err)
+		}
+		if _, err := poll(other, resp.DeviceCode); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+
+		// user types code in lower case without dash
+		typed := strings.ToLower(strings.Replace(resp.UserCode, "-", "", 1))
+		pending, err := store.GetDeviceCode(typed)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if pending.ClientId != client.ID || pending.Scope != "post" || pending.PollInterval != util.DeviceCodeInterval+5 {
+			t.Errorf("unexpected device authorization %+v", pending)
+		}
+		if err := store.ApproveDeviceCode(typed, userID); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := store.GetDeviceCode(typed); err == nil || err.Error() != util.InvalidUserCode {
+			t.Errorf("expected %s, got %v", util.InvalidUserCode, err)
+		}
+
+		token, err := poll(client, resp.DeviceCode)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		access, err := store.GetByAccess(token.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access.UserId != userID || access.Scope != "post" || token.RefreshToken == "" {
+			t.Errorf("unexpected access token %+v", access)
+		}
+		if _, err := poll(client, resp.DeviceCode); err == nil || err.Error() != util.InvalidDeviceCode {
+			t.Errorf("expected %s, got %v", util.InvalidDeviceCode, err)
+		}
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		resp, err := store.CreateDeviceCode(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := store.DenyDeviceCode(resp.UserCode); err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := store.ApproveDeviceCode(resp.UserCode, userID); err == nil || err.Error() != util.InvalidUserCode {
+			t.Errorf("expected %s, got %v", util.InvalidUserCode, err)
+		}
+		if _, err := poll(client, resp.DeviceCode); err == nil || err.Error() != util.AccessDenied {
+			t.Errorf("expected %s, got %v", util.AccessDenied, err)
+		}
+	})
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		wrongSecret := request
+		wrongSecret.ClientSecret = "wrong"
+		if _, err := store.CreateDeviceCode(wrongSecret); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+		if err := store.ApproveDeviceCode("BCDF-GHJK", userID); err == nil || err.Error() != util.InvalidUserCode {
+			t.Errorf("expected %s, got %v", util.InvalidUserCode, err)
+		}
+		if _, err := poll(client, "forged"); err == nil || err.Error() != util.InvalidDeviceCode {
+			t.Errorf("expected %s, got %v", util.InvalidDeviceCode, err)
+		}
+	})
+}
+
+func TestDeviceCodeExpired(t *testing.T) {
+	backend := NewDefaultMemoryStore()
+	store := NewManager(backend)
+	defer store.Close()
+	client, err := store.CreateAuthCodeClient(userID, "tv app", []string{testRedirectURI}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := store.CreateDeviceCode(model.DeviceCodeRequest{ClientID: client.ID, ExpiresIn: time.Millisecond})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	time.Sleep(time.Second)
+	if err := store.ApproveDeviceCode(resp.UserCode, userID); err == nil || err.Error() != util.InvalidUserCode {
+		t.Errorf("expected %s, got %v", util.InvalidUserCode, err)
+	}
+	_, err = store.PollDeviceCode(resp.DeviceCode, &model.Token{ClientID: client.ID})
+	if err == nil || err.Error() != util.ExpiredToken {
+		t.Errorf("expected %s, got %v", util.ExpiredToken, err)
+	}
+	if _, err := backend.Clean(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(backend.devices) != 0 || len(backend.userCodes) != 0 {
+		t.Errorf("expected expired device authorization to be cleaned, got %d devices and %d user codes", len(backend.devices), len(backend.userCodes))
+	}
+}
@@ -4,18 +4,56 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	oauth2 "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/example/common"
 	"github.com/gobeam/golang-oauth/example/core/models"
 	"github.com/gobeam/golang-oauth/example/shared/passhash"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
 	"net/http"
+	"net/url"
+	"time"
 )
 
+type DeviceCodeRequest struct {
+	ClientID     string `json:"client_id" binding:"required"`
+	ClientSecret string `json:"client_secret"`
+	Scope        string `json:"scope"`
+}
+
+type ClientJWKSRequest struct {
+	ClientID string      `json:"client_id" binding:"required"`
+	JWKS     util.JWKSet `json:"jwks" binding:"required"`
+}
+
+type ClientSecretRequest struct {
+	ClientID string `json:"client_id" binding:"required"`
+	Grace    int64  `json:"grace"` // seconds the current secret stays valid
+}
+
+type DeviceApproval struct {
+	UserCode string `json:"user_code" binding:"required"`
+	Approve  bool   `json:"approve"`
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type" binding:"required"`
+	ClientID            string `json:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" binding:"required"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Nonce               string `json:"nonce"`
+}
+
 type AuthController struct {
-	store *oauth2.Store
+	manager *oauth2.Manager
 	Controller
 }
 
-func NewAuthController(store *oauth2.Store) *AuthController {
-	return &AuthController{store: store}
+func NewAuthController(manager *oauth2.Manager) *AuthController {
+	return &AuthController{manager: manager}
 }
 
 func (controller AuthController) Register(c *gin.Context) {
@@ -30,13 +68,200 @@ func (controller AuthController) Register(c *gin.Context) {
 	controller.SuccessResponse(c, map[string]interface{}{"email": user.Email})
 }
 
-func (controller AuthController) Client(c *gin.Context) {
-	client, err := controller.store.CreateClient(1,"test app")
+// ClientJWKS registers public keys the client of logged in user signs client assertions and jwt bearer grants with
+func (controller AuthController) ClientJWKS(c *gin.Context) {
+	var request ClientJWKSRequest
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		_ = c.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	clientId, ok := controller.ownClient(c, request.ClientID)
+	if !ok {
+		return
+	}
+	if err := controller.manager.SetClientJWKS(clientId, request.JWKS); err != nil {
+		controller.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	controller.SuccessResponse(c, map[string]interface{}{"client_id": clientId})
+}
+
+// RotateClientSecret issues new secret to the client of logged in user, the current secret keeps working
+// for grace seconds so deployments can switch to the new one
+func (controller AuthController) RotateClientSecret(c *gin.Context) {
+	var request ClientSecretRequest
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		_ = c.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	clientId, ok := controller.ownClient(c, request.ClientID)
+	if !ok {
+		return
+	}
+	secret, err := controller.manager.RotateClientSecret(clientId, time.Duration(request.Grace)*time.Second)
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	controller.SuccessResponse(c, map[string]interface{}{"client_id": clientId, "client_secret": secret})
+}
+
+// FinishSecretRotation stops accepting previous secret of the client of logged in user before its grace ends
+func (controller AuthController) FinishSecretRotation(c *gin.Context) {
+	var request ClientSecretRequest
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		_ = c.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	clientId, ok := controller.ownClient(c, request.ClientID)
+	if !ok {
+		return
+	}
+	if err := controller.manager.FinishSecretRotation(clientId); err != nil {
+		controller.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	controller.SuccessResponse(c, map[string]interface{}{"client_id": clientId})
+}
+
+// ownClient parses id of client owned by logged in user, error response is written otherwise
+func (controller AuthController) ownClient(c *gin.Context, id string) (uuid.UUID, bool) {
+	userId := c.GetInt64("user_id")
+	if userId == 0 {
+		controller.ErrorResponse(c, http.StatusForbidden, "access_denied")
+		return uuid.Nil, false
+	}
+	clientId, err := uuid.Parse(id)
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusUnprocessableEntity, "invalid_client")
+		return uuid.Nil, false
+	}
+	client, err := controller.manager.GetClient(clientId)
+	if err != nil || client.UserId != userId {
+		controller.ErrorResponse(c, http.StatusForbidden, "access_denied")
+		return uuid.Nil, false
+	}
+	return clientId, true
+}
+
+// Authorize issues authorization code once logged in user approved the request of a client,
+// response holds redirect uri with code and state the user agent should be sent to
+func (controller AuthController) Authorize(c *gin.Context) {
+	var request AuthorizeRequest
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		_ = c.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	if request.ResponseType != "code" {
+		controller.ErrorResponse(c, http.StatusBadRequest, "unsupported_response_type")
+		return
+	}
+	clientId, err := uuid.Parse(request.ClientID)
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusBadRequest, "invalid_client")
+		return
+	}
+	redirect, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	userId := c.GetInt64("user_id")
+	if userId == 0 {
+		controller.ErrorResponse(c, http.StatusForbidden, "access_denied")
+		return
+	}
+	code, err := controller.manager.CreateAuthCode(model.AuthCodeRequest{
+		ClientID:            clientId,
+		UserID:              userId,
+		RedirectURI:         request.RedirectURI,
+		Scope:               request.Scope,
+		CodeChallenge:       request.CodeChallenge,
+		CodeChallengeMethod: request.CodeChallengeMethod,
+		Nonce:               request.Nonce,
+		ExpiresIn:           time.Minute * 5,
+	})
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	redirect.RawQuery = query.Encode()
+	controller.SuccessResponse(c, map[string]interface{}{"redirect_uri": redirect.String()})
+}
+
+// DeviceCode starts device authorization grant for clients which cannot receive redirects such as CLIs and TVs,
+// the user enters returned user code at verification uri while the client polls the token endpoint
+func (controller AuthController) DeviceCode(c *gin.Context) {
+	var request DeviceCodeRequest
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		_ = c.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	clientId, err := uuid.Parse(request.ClientID)
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	verificationURI := ""
+	if key := common.GetConfig("oauth", "verification_uri"); key != nil {
+		verificationURI = key.String()
+	}
+	resp, err := controller.manager.CreateDeviceCode(model.DeviceCodeRequest{
+		ClientID:        clientId,
+		ClientSecret:    request.ClientSecret,
+		Scope:           request.Scope,
+		VerificationURI: verificationURI,
+	})
 	if err != nil {
 		controller.ErrorResponse(c, http.StatusUnauthorized, err.Error())
 		return
 	}
-	controller.SuccessResponse(c, client)
+	controller.SuccessResponse(c, resp)
+}
+
+// Device shows client and scope of pending device authorization to logged in user before approval
+func (controller AuthController) Device(c *gin.Context) {
+	deviceCode, err := controller.manager.GetDeviceCode(c.Param("user_code"))
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	client, err := controller.manager.GetClient(deviceCode.ClientId)
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	controller.SuccessResponse(c, map[string]interface{}{"client": client.Name, "scope": deviceCode.Scope})
+}
+
+// ApproveDevice approves or denies pending device authorization on behalf of logged in user
+func (controller AuthController) ApproveDevice(c *gin.Context) {
+	var request DeviceApproval
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
+		_ = c.AbortWithError(http.StatusUnprocessableEntity, err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	userId := c.GetInt64("user_id")
+	if userId == 0 {
+		controller.ErrorResponse(c, http.StatusForbidden, "access_denied")
+		return
+	}
+	var err error
+	if request.Approve {
+		err = controller.manager.ApproveDeviceCode(request.UserCode, userId)
+	} else {
+		err = controller.manager.DenyDeviceCode(request.UserCode)
+	}
+	if err != nil {
+		controller.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	controller.SuccessResponse(c, map[string]interface{}{"approved": request.Approve})
 }
 
 func (controller AuthController) Token(c *gin.Context) {
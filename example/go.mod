@@ -13,3 +13,5 @@ require (
 	github.com/stvp/rollbar v0.5.1
 	golang.org/x/crypto v0.0.0-20200406173513-056763e48d71
 )
+
+replace github.com/gobeam/golang-oauth => ../
@@ -6,9 +6,13 @@ import (
 	oauth2 "github.com/gobeam/golang-oauth"
 	"github.com/gobeam/golang-oauth/example/common"
 	"github.com/gobeam/golang-oauth/example/core/models"
+	"github.com/gobeam/golang-oauth/example/middlewares"
 	"github.com/gobeam/golang-oauth/example/routers"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
 	"github.com/jinzhu/gorm"
 	"log"
+	"strings"
 )
 
 func main() {
@@ -18,10 +22,12 @@ func main() {
 		panic(err)
 	}
 	defer db.Close()
-	store := oauth2.NewDefaultStore(
-		oauth2.NewConfig(dbUrl),
-	)
-	defer store.Close()
+	keys, err := oauth2.NewDefaultKeyProvider()
+	if err != nil {
+		panic(err)
+	}
+	manager := newManager(dbUrl, keys)
+	defer manager.Close()
 	models.InitializeDb(db.Debug())
 
 	// register custom validator
@@ -29,10 +35,61 @@ func main() {
 	newValidator.RegisterValidator()
 
 	// router setup
-	router := routers.SetupRouter(store)
+	router := routers.SetupRouter(manager, keys)
 
 	serverError := router.Run(fmt.Sprintf(":%s", common.GetConfig("system", "httpport").String()))
 	if serverError != nil {
 		log.Fatalf("Server failed to start %v ", serverError)
 	}
 }
+
+// newManager creates oauth token manager on top of token store selected by [oauth] store config,
+// keys are shared with the JWKS endpoint, client assertions are accepted for [oauth] assertion_audience,
+// token endpoint url under [oauth] issuer by default,
+// ID tokens are issued for openid scope once [oauth] issuer is set
+func newManager(dbUrl string, keys oauth2.KeyProvider) *oauth2.Manager {
+	audience := ""
+	if key := common.GetConfig("oauth", "assertion_audience"); key != nil {
+		audience = key.String()
+	}
+	issuer := ""
+	if key := common.GetConfig("oauth", "issuer"); key != nil {
+		issuer = key.String()
+	}
+	// assertions name the token endpoint as their audience unless configured otherwise
+	if audience == "" && issuer != "" {
+		audience = strings.TrimSuffix(issuer, "/") + util.TokenPath
+	}
+	manager := oauth2.NewManager(newTokenStore(dbUrl))
+	manager.SetKeyProvider(keys)
+	manager.SetAssertionAudience(audience)
+	manager.SetSecurityEventHandler(logSecurityEvent)
+	manager.SetIssuer(issuer)
+	manager.SetClaimsProvider(oauth2.ClaimsProviderFunc(middleware.UserClaims))
+	manager.SetRegistrationScopes("post", "category")
+	return manager
+}
+
+// newTokenStore creates token store selected by [oauth] store config,
+// "memory" keeps tokens in process memory, "sqlite" uses [oauth] path file, anything else uses mysql,
+// replicas sharing the database elect one of them to clean expired tokens when [oauth] gc_leader_election is true
+func newTokenStore(dbUrl string) oauth2.TokenStore {
+	key := common.GetConfig("oauth", "store")
+	if key != nil && key.String() == "memory" {
+		return oauth2.NewDefaultMemoryStore()
+	}
+	config := oauth2.NewConfig(dbUrl)
+	if key != nil && key.String() == "sqlite" {
+		config = oauth2.NewSQLiteConfig(common.GetConfig("oauth", "path").String())
+	}
+	store := oauth2.NewDefaultStore(config)
+	if key := common.GetConfig("oauth", "gc_leader_election"); key != nil && key.String() == "true" {
+		store.EnableGCLeaderElection()
+	}
+	return store
+}
+
+// logSecurityEvent logs security events such as refresh token reuse, a production server would alert on them
+func logSecurityEvent(event model.SecurityEvent) {
+	log.Printf("security event %s: user %d, client %s, family %s", event.Type, event.UserId, event.ClientId, event.FamilyId)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	oauth2 "github.com/gobeam/golang-oauth"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/google/uuid"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -33,6 +35,11 @@ const (
 	InvalidHeader      = "Authorization header is invalid!"
 	RefreshToken       = "refresh_token"
 	Password           = "password"
+	AuthorizationCode  = "authorization_code"
+	ClientCredentials  = "client_credentials"
+	DeviceCode         = "urn:ietf:params:oauth:grant-type:device_code"
+	TokenExchange      = "urn:ietf:params:oauth:grant-type:token-exchange"
+	JWTBearer          = "urn:ietf:params:oauth:grant-type:jwt-bearer"
 	Expiry             = 3600
 )
 
@@ -51,16 +58,54 @@ type RefreshTokenCredential struct {
 	Scope        string `json:"scope,omitempty"`
 }
 
-type AccessTokenPayload struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiryTime   int64  `json:"expiry_time"`
+type AuthorizationCodeCredential struct {
+	ClientID     string `json:"client_id" binding:"required"`
+	ClientSecret string `json:"client_secret"`
+	Code         string `json:"code" binding:"required"`
+	RedirectURI  string `json:"redirect_uri" binding:"required"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type ClientCredential struct {
+	ClientID            string `json:"client_id"`
+	ClientSecret        string `json:"client_secret"`
+	ClientAssertionType string `json:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion"`
+	Scope               string `json:"scope,omitempty"`
 }
 
-type Profile struct {
-	Email string `json:"email"`
-	Name  string `json:"name"`
-	ID    uint   `json:"id"`
+type JWTBearerCredential struct {
+	ClientID            string `json:"client_id"`
+	ClientSecret        string `json:"client_secret"`
+	ClientAssertionType string `json:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion"`
+	Assertion           string `json:"assertion" binding:"required"`
+	Scope               string `json:"scope,omitempty"`
+}
+
+type DeviceCodeCredential struct {
+	ClientID     string `json:"client_id" binding:"required"`
+	ClientSecret string `json:"client_secret"`
+	DeviceCode   string `json:"device_code" binding:"required"`
+}
+
+type TokenExchangeCredential struct {
+	ClientID         string `json:"client_id" binding:"required"`
+	ClientSecret     string `json:"client_secret" binding:"required"`
+	SubjectToken     string `json:"subject_token" binding:"required"`
+	SubjectTokenType string `json:"subject_token_type" binding:"required"`
+	ActorToken       string `json:"actor_token"`
+	ActorTokenType   string `json:"actor_token_type"`
+	Scope            string `json:"scope"`
+	Audience         string `json:"audience"`
+}
+
+type AccessTokenPayload struct {
+	AccessToken     string `json:"access_token"`
+	RefreshToken    string `json:"refresh_token"`
+	ExpiryTime      int64  `json:"expiry_time"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
 }
 
 type GrantType struct {
@@ -98,7 +143,7 @@ func findInSlice(slice []string, val string) bool {
 
 
 // Check If access token is valid and have proper scope
-func OauthMiddleware(store *oauth2.Store) gin.HandlerFunc {
+func OauthMiddleware(manager *oauth2.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.Request.Header.Get("Authorization")
 		if authHeader == "" {
@@ -112,7 +157,7 @@ func OauthMiddleware(store *oauth2.Store) gin.HandlerFunc {
 			return
 		}
 
-		tokenInfo, err := store.GetByAccess(parts[1])
+		tokenInfo, err := manager.GetByAccess(parts[1])
 		if err != nil {
 			oAuthAbort(c, err.Error())
 			return
@@ -138,24 +183,27 @@ func OauthMiddleware(store *oauth2.Store) gin.HandlerFunc {
 			}
 		}
 
-		var user models.User
-		user.ID = uint(tokenInfo.UserId)
-		user.FindById()
-		if user.ID < 1 {
+		// tokens of client credentials grant belong to the client, not to any user
+		if tokenInfo.UserId == 0 {
+			c.Set("client", tokenInfo.ClientId)
+			c.Next()
+			return
+		}
+
+		claims, err := UserClaims(tokenInfo.UserId, strings.Fields(tokenInfo.Scope))
+		if err != nil {
 			oAuthAbort(c, InvalidUser)
 			return
 		}
-		c.Set("user", Profile{
-			Email: user.Email,
-			Name:  user.Name,
-			ID:    user.ID,
-		})
+		c.Set("user_id", tokenInfo.UserId)
+		c.Set("user", claims)
 		c.Next()
 	}
 }
 
-// Return Access Token for valid client and user credential
-func AccessToken(store *oauth2.Store) gin.HandlerFunc {
+// Return Access Token for valid client and user credential,
+// JSON variant of the form encoded token endpoint served by server package
+func AccessToken(manager *oauth2.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var grant GrantType
 		if err := c.ShouldBindBodyWith(&grant, binding.JSON); err != nil {
@@ -192,7 +240,7 @@ func AccessToken(store *oauth2.Store) gin.HandlerFunc {
 
 				accessToken := createToken(credential, user)
 
-				token, err := store.Create(accessToken)
+				token, err := manager.Create(accessToken)
 				if err != nil {
 					oAuthAbort(c, err.Error())
 					return
@@ -208,28 +256,49 @@ func AccessToken(store *oauth2.Store) gin.HandlerFunc {
 				_ = c.AbortWithError(422, err).SetType(gin.ErrorTypeBind)
 				return
 			}
-			refreshToken, err := store.GetByRefresh(credential.RefreshToken)
+			clientId, err := uuid.Parse(credential.ClientID)
+			if err != nil {
+				oAuthAbort(c, InvalidClient)
+				return
+			}
+			// rotated refresh token stays in the family of the old one, reusing the old one revokes the family
+			token, err := manager.RotateRefreshToken(credential.RefreshToken, &model.Token{
+				ClientID:        clientId,
+				ClientSecret:    credential.ClientSecret,
+				Scope:           credential.Scope,
+				AccessCreateAt:  time.Now(),
+				AccessExpiresIn: time.Second * Expiry,
+				RefreshCreateAt: time.Now(),
+			})
 			if err != nil {
 				oAuthAbort(c, err.Error())
 				return
 			}
-			var scope string
-			if credential.Scope != "" {
-				scope = credential.Scope
-			} else {
-				scope = refreshToken.Scope
+			c.Set("accessToken", AccessTokenPayload{
+				AccessToken:  token.AccessToken,
+				RefreshToken: token.RefreshToken,
+				ExpiryTime:   token.ExpiredAt,
+			})
+		case AuthorizationCode:
+			var credential AuthorizationCodeCredential
+			if err := c.ShouldBindBodyWith(&credential, binding.JSON); err != nil {
+				_ = c.AbortWithError(422, err).SetType(gin.ErrorTypeBind)
+				return
+			}
+			clientId, err := uuid.Parse(credential.ClientID)
+			if err != nil {
+				oAuthAbort(c, InvalidClient)
+				return
 			}
 			accessToken := &model.Token{
-				ClientID:        uuid.MustParse(credential.ClientID),
+				ClientID:        clientId,
 				ClientSecret:    credential.ClientSecret,
-				UserID:          int64(refreshToken.UserId),
-				Scope:           scope,
+				RedirectURI:     credential.RedirectURI,
 				AccessCreateAt:  time.Now(),
 				AccessExpiresIn: time.Second * Expiry,
 				RefreshCreateAt: time.Now(),
 			}
-
-			token, err := store.Create(accessToken)
+			token, err := manager.ExchangeAuthCode(credential.Code, credential.CodeVerifier, accessToken)
 			if err != nil {
 				oAuthAbort(c, err.Error())
 				return
@@ -238,6 +307,125 @@ func AccessToken(store *oauth2.Store) gin.HandlerFunc {
 				AccessToken:  token.AccessToken,
 				RefreshToken: token.RefreshToken,
 				ExpiryTime:   token.ExpiredAt,
+				IDToken:      token.IDToken,
+			})
+		case ClientCredentials:
+			var credential ClientCredential
+			if err := c.ShouldBindBodyWith(&credential, binding.JSON); err != nil {
+				_ = c.AbortWithError(422, err).SetType(gin.ErrorTypeBind)
+				return
+			}
+			// client id may be omitted when client authenticates with client assertion
+			clientId, err := parseClientID(credential.ClientID)
+			if err != nil {
+				oAuthAbort(c, InvalidClient)
+				return
+			}
+			token, err := manager.CreateClientToken(&model.Token{
+				ClientID:            clientId,
+				ClientSecret:        credential.ClientSecret,
+				ClientAssertionType: credential.ClientAssertionType,
+				ClientAssertion:     credential.ClientAssertion,
+				Scope:               credential.Scope,
+				AccessCreateAt:      time.Now(),
+				AccessExpiresIn:     time.Second * Expiry,
+			})
+			if err != nil {
+				oAuthAbort(c, err.Error())
+				return
+			}
+			c.Set("accessToken", AccessTokenPayload{
+				AccessToken: token.AccessToken,
+				ExpiryTime:  token.ExpiredAt,
+			})
+		case DeviceCode:
+			var credential DeviceCodeCredential
+			if err := c.ShouldBindBodyWith(&credential, binding.JSON); err != nil {
+				_ = c.AbortWithError(422, err).SetType(gin.ErrorTypeBind)
+				return
+			}
+			clientId, err := uuid.Parse(credential.ClientID)
+			if err != nil {
+				oAuthAbort(c, InvalidClient)
+				return
+			}
+			token, err := manager.PollDeviceCode(credential.DeviceCode, &model.Token{
+				ClientID:        clientId,
+				ClientSecret:    credential.ClientSecret,
+				AccessCreateAt:  time.Now(),
+				AccessExpiresIn: time.Second * Expiry,
+				RefreshCreateAt: time.Now(),
+			})
+			if err != nil {
+				// authorization_pending and slow_down tell the client to keep polling (RFC 8628 3.5)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			c.Set("accessToken", AccessTokenPayload{
+				AccessToken:  token.AccessToken,
+				RefreshToken: token.RefreshToken,
+				ExpiryTime:   token.ExpiredAt,
+			})
+		case TokenExchange:
+			var credential TokenExchangeCredential
+			if err := c.ShouldBindBodyWith(&credential, binding.JSON); err != nil {
+				_ = c.AbortWithError(422, err).SetType(gin.ErrorTypeBind)
+				return
+			}
+			clientId, err := uuid.Parse(credential.ClientID)
+			if err != nil {
+				oAuthAbort(c, InvalidClient)
+				return
+			}
+			token, err := manager.ExchangeToken(model.TokenExchangeRequest{
+				ClientID:         clientId,
+				ClientSecret:     credential.ClientSecret,
+				SubjectToken:     credential.SubjectToken,
+				SubjectTokenType: credential.SubjectTokenType,
+				ActorToken:       credential.ActorToken,
+				ActorTokenType:   credential.ActorTokenType,
+				Scope:            credential.Scope,
+				Audience:         credential.Audience,
+				AccessCreateAt:   time.Now(),
+				AccessExpiresIn:  time.Second * Expiry,
+			})
+			if err != nil {
+				oAuthAbort(c, err.Error())
+				return
+			}
+			c.Set("accessToken", AccessTokenPayload{
+				AccessToken:     token.AccessToken,
+				ExpiryTime:      token.ExpiredAt,
+				IssuedTokenType: token.IssuedTokenType,
+			})
+		case JWTBearer:
+			var credential JWTBearerCredential
+			if err := c.ShouldBindBodyWith(&credential, binding.JSON); err != nil {
+				_ = c.AbortWithError(422, err).SetType(gin.ErrorTypeBind)
+				return
+			}
+			clientId, err := parseClientID(credential.ClientID)
+			if err != nil {
+				oAuthAbort(c, InvalidClient)
+				return
+			}
+			token, err := manager.CreateAssertionToken(credential.Assertion, &model.Token{
+				ClientID:            clientId,
+				ClientSecret:        credential.ClientSecret,
+				ClientAssertionType: credential.ClientAssertionType,
+				ClientAssertion:     credential.ClientAssertion,
+				Scope:               credential.Scope,
+				AccessCreateAt:      time.Now(),
+				AccessExpiresIn:     time.Second * Expiry,
+			})
+			if err != nil {
+				oAuthAbort(c, err.Error())
+				return
+			}
+			c.Set("accessToken", AccessTokenPayload{
+				AccessToken: token.AccessToken,
+				ExpiryTime:  token.ExpiredAt,
 			})
 		default:
 			oAuthAbort(c, InvalidGrantType)
@@ -247,6 +435,62 @@ func AccessToken(store *oauth2.Store) gin.HandlerFunc {
 	}
 }
 
+// RegistrationUser authorizes dynamic client registration with access token of logged in user, who owns registered clients
+func RegistrationUser(manager *oauth2.Manager) func(r *http.Request) (int64, error) {
+	return func(r *http.Request) (int64, error) {
+		parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if !(len(parts) == 2 && parts[0] == "Bearer") {
+			return 0, errors.New(InvalidHeader)
+		}
+		tokenInfo, err := manager.GetByAccess(parts[1])
+		if err != nil {
+			return 0, err
+		}
+		if tokenInfo.UserId == 0 {
+			return 0, errors.New(InvalidUser)
+		}
+		return tokenInfo.UserId, nil
+	}
+}
+
+// UserClaims returns OpenID Connect claims of user, it is the claims provider of the token manager,
+// the manager releases only claims of scopes granted to the client
+func UserClaims(userId int64, scopes []string) (map[string]interface{}, error) {
+	var user models.User
+	user.ID = uint(userId)
+	user.FindById()
+	if user.ID < 1 {
+		return nil, errors.New(InvalidUser)
+	}
+	return map[string]interface{}{
+		"sub":        strconv.FormatInt(userId, 10),
+		"name":       user.Name,
+		"email":      user.Email,
+		"updated_at": user.UpdatedAt.Unix(),
+	}, nil
+}
+
+// VerifyUser checks resource owner credentials of password grant and returns id of the user
+func VerifyUser(username, password string) (int64, error) {
+	user := models.User{Email: username}
+	user.FindByEmail()
+	if user.ID < 1 {
+		return 0, errors.New(InvalidUser)
+	}
+	if err := passhash.VerifyPassword(user.Password, password); err != nil {
+		return 0, err
+	}
+	return int64(user.ID), nil
+}
+
+// parseClientID parses optional client id, empty id is uuid.Nil
+func parseClientID(clientId string) (uuid.UUID, error) {
+	if clientId == "" {
+		return uuid.Nil, nil
+	}
+	return uuid.Parse(clientId)
+}
+
 func createToken(cred PasswordCredential, user models.User) (accessToken *model.Token) {
 	accessToken = &model.Token{
 		ClientID:        uuid.MustParse(cred.ClientID),
@@ -5,6 +5,8 @@ import (
 	oauth2 "github.com/gobeam/golang-oauth"
 	"github.com/gobeam/golang-oauth/example/controllers"
 	"github.com/gobeam/golang-oauth/example/middlewares"
+	"github.com/gobeam/golang-oauth/server"
+	"github.com/gobeam/golang-oauth/util"
 )
 
 var Router *gin.Engine
@@ -21,27 +23,54 @@ func ResourceFulRouter(r gin.IRouter, controller controllers.ResourceController)
 	r.DELETE("/:id", controller.Destroy)
 }
 
-func SetupRouter(store *oauth2.Store) *gin.Engine {
+func SetupRouter(manager *oauth2.Manager, keys oauth2.KeyProvider) *gin.Engine {
 	router := gin.Default()
 	router.Use(middleware.CORS())
-	authController := controllers.NewAuthController(store)
+	authController := controllers.NewAuthController(manager)
+
+	// standard form encoded token endpoint, token state for resource servers which cannot decode tokens themselves
+	// revocation for clients logging out and userinfo for OpenID Connect relying parties,
+	// public keys for resource servers verifying JWT access tokens and metadata clients configure themselves from
+	srv := server.NewServer(manager)
+	srv.RegisterGrant(util.GrantPassword, server.PasswordGrant(manager, middleware.VerifyUser))
+	srv.ServeJWKS(keys, 0)
+	srv.SetAuthorizationEndpoint("/api/v1/authorize")
+	srv.SetDeviceAuthorizationEndpoint("/api/v1/device/code")
+	// logged in users register clients they own with their access token
+	srv.EnableRegistration(middleware.RegistrationUser(manager))
+	router.POST(util.RegistrationPath, gin.WrapH(srv))
+	router.Any(util.RegistrationPath+"/:client_id", gin.WrapH(srv))
+	router.GET(util.JWKSPath, gin.WrapH(srv))
+	router.GET(util.ServerMetadataPath, gin.WrapH(srv))
+	router.GET(util.OpenIDConfigPath, gin.WrapH(srv))
+	router.POST(util.TokenPath, gin.WrapH(srv))
+	router.POST(util.IntrospectionPath, gin.WrapH(srv))
+	router.POST(util.RevocationPath, gin.WrapH(srv))
+	router.GET(util.UserInfoPath, gin.WrapH(srv))
+	router.POST(util.UserInfoPath, gin.WrapH(srv))
 
 	pub := router.Group("/api/v1")
 	pub.Use(middleware.Errors())
 	{
 		authorized := pub.Group("/auth")
-		authorized.Use(middleware.AccessToken(store))
+		authorized.Use(middleware.AccessToken(manager))
 		{
 			authorized.POST("/token", authController.Token)
 		}
 
 		pub.POST("/register", authController.Register)
-		pub.POST("/client", authController.Client)
+		pub.POST("/device/code", authController.DeviceCode)
 
 		priv := pub.Group("/")
-		priv.Use(middleware.OauthMiddleware(store))
+		priv.Use(middleware.OauthMiddleware(manager))
 		{
 			priv.GET("/profile", authController.Profile)
+			priv.POST("/authorize", authController.Authorize)
+			priv.GET("/device/:user_code", authController.Device)
+			priv.POST("/device", authController.ApproveDevice)
+			priv.POST("/client/jwks", authController.ClientJWKS)
+			priv.POST("/client/secret", authController.RotateClientSecret)
+			priv.POST("/client/secret/finish", authController.FinishSecretRotation)
 
 			postController := controllers.NewPostController()
 			ResourceFulRouter(priv.Group("/post"), postController)
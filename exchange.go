@@ -0,0 +1,154 @@
+package golang_oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// validateTokenType checks type of subject or actor token, only access tokens issued by this server are accepted
+func validateTokenType(tokenType string) error {
+	if tokenType != util.TokenTypeAccessToken && tokenType != util.TokenTypeJWT {
+		return errors.New(util.UnsupportedTokenType)
+	}
+	return nil
+}
+
+// exchangeScope resolves scope of exchanged token, requested scope must be within scope of subject token,
+// empty requested scope keeps it, subject scope "*" allows any scope
+func exchangeScope(subjectScope, requested string) (string, error) {
+	if requested == "" {
+		return subjectScope, nil
+	}
+	allowed := strings.Fields(subjectScope)
+	for _, scope := range strings.Fields(requested) {
+		found := false
+		for _, item := range allowed {
+			if item == scope || item == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", errors.New(util.ExcessiveScope)
+		}
+	}
+	return requested, nil
+}
+
+// newExchangedToken builds access token for client exchanging subject token,
+// with actor token (delegation) the actor becomes the outermost entry of act chain carried over from subject token,
+// without it (impersonation) act chain of subject token is kept as is,
+// returned model is not persisted, it is up to Manager to save it in its store
+func newExchangedToken(client model.Clients, subject, actor *model.AccessTokens, request model.TokenExchangeRequest) (*model.AccessTokens, error) {
+	scope, err := exchangeScope(subject.Scope, request.Scope)
+	if err != nil {
+		return nil, err
+	}
+	act := subject.Act
+	if actor != nil {
+		previous, err := subject.Actor()
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(model.Actor{
+			Subject:  tokenSubject(actor),
+			ClientId: actor.ClientId.String(),
+			Act:      previous,
+		})
+		if err != nil {
+			return nil, err
+		}
+		act = string(encoded)
+	}
+
+	// exchanged token never outlives subject token
+	expiredAt := subject.ExpiredAt
+	if request.AccessExpiresIn > 0 {
+		if requested := request.AccessCreateAt.Add(request.AccessExpiresIn).Unix(); requested < expiredAt {
+			expiredAt = requested
+		}
+	}
+	return &model.AccessTokens{
+		Model: model.Model{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		AccessTokenPayload: model.AccessTokenPayload{
+			UserId:    subject.UserId,
+			ClientId:  client.ID,
+			ExpiredAt: expiredAt,
+		},
+		Scope:    scope,
+		Audience: request.Audience,
+		Act:      act,
+	}, nil
+}
+
+// prepareTokenExchange authenticates client, validates subject and actor tokens with GetByAccess
+// and builds exchanged access token, empty creation time of request defaults to now
+func (m *Manager) prepareTokenExchange(request *model.TokenExchangeRequest) (*model.AccessTokens, error) {
+	if request.AccessCreateAt.IsZero() {
+		request.AccessCreateAt = time.Now()
+	}
+	client, err := m.AuthenticateClient(model.ClientCredentials{
+		ClientID:            request.ClientID,
+		ClientSecret:        request.ClientSecret,
+		ClientAssertionType: request.ClientAssertionType,
+		ClientAssertion:     request.ClientAssertion,
+		AuthMethod:          request.AuthMethod,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := requireConfidential(client); err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(util.GrantTokenExchange) {
+		return nil, errors.New(util.UnauthorizedClient)
+	}
+	if err := validateTokenType(request.SubjectTokenType); err != nil {
+		return nil, err
+	}
+	subject, err := m.GetByAccess(request.SubjectToken)
+	if err != nil {
+		return nil, err
+	}
+	var actor *model.AccessTokens
+	if request.ActorToken != "" {
+		if err := validateTokenType(request.ActorTokenType); err != nil {
+			return nil, err
+		}
+		actor, err = m.GetByAccess(request.ActorToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newExchangedToken(client, subject, actor, *request)
+}
+
+// ExchangeToken swaps subject token validated by GetByAccess for new access token of requesting client (RFC 8693),
+// issued token keeps user of subject token, scope may only be reduced and expiry is capped at expiry of subject token,
+// with actor token the actor is recorded in act chain of issued token, no refresh token is issued
+func (m *Manager) ExchangeToken(request model.TokenExchangeRequest) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	access, err := m.prepareTokenExchange(&request)
+	if err != nil {
+		return tokenResp, err
+	}
+	tokenResp, err = m.issueAccessToken(access, request.AccessCreateAt)
+	if err != nil {
+		return tokenResp, err
+	}
+	err = m.store.InsertAccessToken(*access)
+	if err != nil {
+		return tokenResp, err
+	}
+	tokenResp.IssuedTokenType = util.TokenTypeAccessToken
+	return tokenResp, nil
+}
@@ -0,0 +1,199 @@
+package golang_oauth
+
+import (
+	"crypto"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"testing"
+	"time"
+)
+
+// testTokenExchange runs token exchange with impersonation and delegation against given store
+func testTokenExchange(t *testing.T, store *Manager) {
+	app, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	gateway, err := store.CreateClient(userID, "gateway")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientScope(gateway.ID, "*"); err != nil {
+		t.Fatal(err.Error())
+	}
+	subjectExpiresIn := time.Minute
+	userToken, err := store.Create(&model.Token{
+		ClientID:        app.ID,
+		ClientSecret:    app.Secret,
+		UserID:          userID,
+		Scope:           "read write post",
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: subjectExpiresIn,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	gatewayToken, err := store.CreateClientToken(&model.Token{
+		ClientID:        gateway.ID,
+		ClientSecret:    gateway.Secret,
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	request := model.TokenExchangeRequest{
+		ClientID:         gateway.ID,
+		ClientSecret:     gateway.Secret,
+		SubjectToken:     userToken.AccessToken,
+		SubjectTokenType: util.TokenTypeAccessToken,
+		Scope:            "read",
+		Audience:         "orders-api",
+		AccessCreateAt:   time.Now(),
+		AccessExpiresIn:  time.Hour,
+	}
+
+	t.Run("Impersonation", func(t *testing.T) {
+		resp, err := store.ExchangeToken(request)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.RefreshToken != "" || resp.IssuedTokenType != util.TokenTypeAccessToken {
+			t.Errorf("unexpected response %+v", resp)
+		}
+		access, err := store.GetByAccess(resp.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access.UserId != userID || access.ClientId != gateway.ID || access.Scope != "read" ||
+			access.Audience != "orders-api" || access.Act != "" {
+			t.Errorf("unexpected access token %+v", access)
+		}
+		if access.ExpiredAt > time.Now().Add(subjectExpiresIn).Unix() {
+			t.Error("exchanged token must not outlive subject token")
+		}
+	})
+
+	t.Run("Delegation", func(t *testing.T) {
+		delegation := request
+		delegation.ActorToken = gatewayToken.AccessToken
+		delegation.ActorTokenType = util.TokenTypeAccessToken
+		resp, err := store.ExchangeToken(delegation)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		access, err := store.GetByAccess(resp.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		actor, err := access.Actor()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if access.UserId != userID || actor == nil || actor.Subject != gateway.ID.String() || actor.Act != nil {
+			t.Fatalf("unexpected actor %+v of %+v", actor, access)
+		}
+
+		// exchanging delegated token again nests previous actor
+		delegation.SubjectToken = resp.AccessToken
+		delegation.ActorToken = userToken.AccessToken
+		resp, err = store.ExchangeToken(delegation)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		access, err = store.GetByAccess(resp.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		actor, err = access.Actor()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if actor == nil || actor.Subject != "1" || actor.ClientId != app.ID.String() ||
+			actor.Act == nil || actor.Act.Subject != gateway.ID.String() {
+			t.Errorf("unexpected actor chain %+v", actor)
+		}
+	})
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		excessive := request
+		excessive.Scope = "read admin"
+		if _, err := store.ExchangeToken(excessive); err == nil || err.Error() != util.ExcessiveScope {
+			t.Errorf("expected %s, got %v", util.ExcessiveScope, err)
+		}
+		unsupported := request
+		unsupported.SubjectTokenType = "urn:ietf:params:oauth:token-type:saml2"
+		if _, err := store.ExchangeToken(unsupported); err == nil || err.Error() != util.UnsupportedTokenType {
+			t.Errorf("expected %s, got %v", util.UnsupportedTokenType, err)
+		}
+		forged := request
+		forged.SubjectToken = "forged"
+		if _, err := store.ExchangeToken(forged); err == nil {
+			t.Error("expected invalid subject token to fail")
+		}
+		wrongSecret := request
+		wrongSecret.ClientSecret = "wrong"
+		if _, err := store.ExchangeToken(wrongSecret); err == nil || err.Error() != util.InvalidClient {
+			t.Errorf("expected %s, got %v", util.InvalidClient, err)
+		}
+	})
+}
+
+func TestExchangedJWTClaims(t *testing.T) {
+	keys := testKeyProvider(t)
+	store := NewManager(NewDefaultMemoryStore())
+	defer store.Close()
+	store.SetKeyProvider(keys)
+	if err := store.SetTokenFormat(util.TokenFormatJWT, util.RS256); err != nil {
+		t.Fatal(err.Error())
+	}
+	client, err := store.CreateClient(userID, "gateway")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientScope(client.ID, "*"); err != nil {
+		t.Fatal(err.Error())
+	}
+	info := &model.Token{ClientID: client.ID, ClientSecret: client.Secret, UserID: userID, Scope: "read",
+		AccessCreateAt: time.Now(), AccessExpiresIn: time.Minute}
+	subject, err := store.Create(info)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	actor, err := store.CreateClientToken(info)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := store.ExchangeToken(model.TokenExchangeRequest{
+		ClientID:         client.ID,
+		ClientSecret:     client.Secret,
+		SubjectToken:     subject.AccessToken,
+		SubjectTokenType: util.TokenTypeJWT,
+		ActorToken:       actor.AccessToken,
+		ActorTokenType:   util.TokenTypeJWT,
+		Audience:         "orders-api",
+		AccessExpiresIn:  time.Second * 30,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var claims model.AccessTokenClaims
+	_, err = util.ParseJWT(resp.AccessToken, &claims, func(header util.JWTHeader) (crypto.PublicKey, error) {
+		key, err := keys.KeyByID(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.Signer.Public(), nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if claims.Subject != "1" || claims.Audience != "orders-api" || claims.Act == nil || claims.Act.Subject != client.ID.String() {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	// empty creation time of request defaults to now
+	now := time.Now().Unix()
+	if claims.IssuedAt < now-5 || claims.IssuedAt > now || claims.ExpiresAt != claims.IssuedAt+30 {
+		t.Errorf("expected token issued now and expiring in 30s, got iat %d exp %d", claims.IssuedAt, claims.ExpiresAt)
+	}
+}
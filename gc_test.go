@@ -0,0 +1,135 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClean(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golang-oauth")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	for name, store := range testStores(dir) {
+		t.Run(name, func(t *testing.T) {
+			defer store.Close()
+			testClean(t, store)
+		})
+	}
+}
+
+// testClean runs garbage collection of expired, revoked and orphaned tokens against given empty store
+func testClean(t *testing.T, store *Manager) {
+	// every statement deletes a single row to run through several batches
+	if batched, ok := store.Store().(interface{ SetGCBatchSize(size int) }); ok {
+		batched.SetGCBatchSize(1)
+	}
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	past := time.Now().Add(-time.Hour * 2)
+	create := func(userId int64, refreshCreateAt time.Time) model.TokenResponse {
+		resp, err := store.Create(&model.Token{
+			ClientID:         client.ID,
+			ClientSecret:     client.Secret,
+			UserID:           userId,
+			AccessCreateAt:   past,
+			AccessExpiresIn:  time.Hour,
+			RefreshCreateAt:  refreshCreateAt,
+			RefreshExpiresIn: time.Hour * 3,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return resp
+	}
+
+	// expired access token without refresh token
+	if _, err := store.CreateClientToken(&model.Token{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		AccessCreateAt:  past,
+		AccessExpiresIn: time.Hour,
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+	// expired access token kept for its live refresh token
+	live := create(userID, time.Now())
+	// expired refresh token and its access token
+	create(userID, time.Now().Add(-time.Hour*4))
+	// revoked access token and its orphaned refresh token
+	create(2, time.Now())
+	if err := store.RevokeByAccessTokens(2); err != nil {
+		t.Fatal(err.Error())
+	}
+	// used refresh token stays while its family is alive, access token of the used one is revoked
+	used := create(userID, time.Now())
+	if _, err := store.RotateRefreshToken(used.RefreshToken, &model.Token{ClientID: client.ID, ClientSecret: client.Secret}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	report, err := store.Store().Clean()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.AccessTokens != 4 || report.RefreshTokens != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report, err := store.Store().Clean(); err != nil || report != (CleanReport{}) {
+		t.Errorf("expected nothing left to clean, got %+v, %v", report, err)
+	}
+	if _, err := store.GetByRefresh(live.RefreshToken); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := store.GetByRefresh(used.RefreshToken); err == nil || err.Error() != util.RefreshTokenRevoked {
+		t.Errorf("expected %s, got %v", util.RefreshTokenRevoked, err)
+	}
+}
+
+func TestGCLeaderElection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golang-oauth")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// replicas share one database
+	config := NewSQLiteConfig(filepath.Join(dir, "oauth.db"))
+	first := NewDefaultStore(config)
+	second := NewDefaultStore(config)
+	defer second.Close()
+	if !first.IsLeader() || !first.acquireGCLease() {
+		t.Error("every instance cleans without leader election")
+	}
+
+	first.EnableGCLeaderElection()
+	second.EnableGCLeaderElection()
+	if first.IsLeader() {
+		t.Error("instance is not leader before it acquires the lease")
+	}
+	if !first.acquireGCLease() || !first.IsLeader() {
+		t.Error("first instance must acquire free lease")
+	}
+	if second.acquireGCLease() || second.IsLeader() {
+		t.Error("second instance must not acquire lease held by first one")
+	}
+	if !first.acquireGCLease() {
+		t.Error("leader must renew its lease")
+	}
+
+	first.Close()
+	if first.IsLeader() {
+		t.Error("closed instance must give up the lease")
+	}
+	if !second.acquireGCLease() || !second.IsLeader() {
+		t.Error("second instance must take over released lease")
+	}
+}
@@ -11,6 +11,8 @@ require (
 	github.com/google/uuid v1.1.1
 	github.com/jinzhu/gorm v1.9.12
 	github.com/json-iterator/go v1.1.9
+	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v2.0.1+incompatible
 	github.com/mitchellh/mapstructure v1.2.2 // indirect
 	github.com/pelletier/go-toml v1.7.0 // indirect
 	github.com/spf13/afero v1.2.2 // indirect
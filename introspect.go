@@ -0,0 +1,63 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+)
+
+// Introspect reports state of access token or refresh token (RFC 7662), hint is token_type_hint of the request
+// and only decides which kind is looked up first, refresh token is inspected without being used
+func (m *Manager) Introspect(token, hint string) model.IntrospectionResponse {
+	if hint == util.TokenTypeHintRefresh {
+		if resp, ok := m.introspectRefresh(token); ok {
+			return resp
+		}
+		resp, _ := m.introspectAccess(token)
+		return resp
+	}
+	if resp, ok := m.introspectAccess(token); ok {
+		return resp
+	}
+	resp, _ := m.introspectRefresh(token)
+	return resp
+}
+
+// introspectAccess reports state of access token, ok is false when token is not an active access token
+func (m *Manager) introspectAccess(token string) (model.IntrospectionResponse, bool) {
+	access, err := m.GetByAccess(token)
+	if err != nil {
+		return model.IntrospectionResponse{}, false
+	}
+	act, err := access.Actor()
+	if err != nil {
+		return model.IntrospectionResponse{}, false
+	}
+	return model.IntrospectionResponse{
+		Active:    true,
+		Scope:     access.Scope,
+		ClientId:  access.ClientId.String(),
+		Subject:   tokenSubject(access),
+		Audience:  access.Audience,
+		ExpiresAt: access.ExpiredAt,
+		IssuedAt:  access.CreatedAt.Unix(),
+		TokenType: util.TokenTypeBearer,
+		Act:       act,
+	}, true
+}
+
+// introspectRefresh reports state of refresh token, ok is false when token is not an active refresh token
+func (m *Manager) introspectRefresh(token string) (model.IntrospectionResponse, bool) {
+	access, refresh, err := m.InspectRefresh(token)
+	if err != nil {
+		return model.IntrospectionResponse{}, false
+	}
+	return model.IntrospectionResponse{
+		Active:    true,
+		Scope:     access.Scope,
+		ClientId:  access.ClientId.String(),
+		Subject:   tokenSubject(access),
+		ExpiresAt: refresh.ExpiredAt,
+		IssuedAt:  refresh.CreatedAt.Unix(),
+		TokenType: util.TokenTypeHintRefresh,
+	}, true
+}
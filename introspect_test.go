@@ -0,0 +1,56 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testIntrospection runs token introspection against given store
+func testIntrospection(t *testing.T, store *Manager) {
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tokens, err := store.Create(&model.Token{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		UserID:          userID,
+		Scope:           "read",
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	resp := store.Introspect(tokens.AccessToken, "")
+	if !resp.Active || resp.Scope != "read" || resp.ClientId != client.ID.String() || resp.Subject != strconv.FormatInt(userID, 10) ||
+		resp.ExpiresAt != tokens.ExpiredAt || resp.IssuedAt == 0 || resp.TokenType != util.TokenTypeBearer {
+		t.Errorf("unexpected access token introspection %+v", resp)
+	}
+
+	// refresh token is not used by introspection
+	if resp := store.Introspect(tokens.RefreshToken, util.TokenTypeHintRefresh); !resp.Active || resp.TokenType != util.TokenTypeHintRefresh ||
+		resp.ExpiresAt != tokens.RefreshExpiredAt || resp.ClientId != client.ID.String() {
+		t.Errorf("unexpected refresh token introspection %+v", resp)
+	}
+	if resp := store.Introspect(tokens.RefreshToken, ""); !resp.Active {
+		t.Error("refresh token must be found without hint")
+	}
+	if resp := store.Introspect(tokens.AccessToken, util.TokenTypeHintRefresh); !resp.Active {
+		t.Error("access token must be found with refresh token hint")
+	}
+	if _, err := store.GetByRefresh(tokens.RefreshToken); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// used and unknown tokens are inactive and nothing else is revealed
+	for _, token := range []string{tokens.RefreshToken, tokens.AccessToken, "unknown"} {
+		if resp := store.Introspect(token, ""); resp != (model.IntrospectionResponse{}) {
+			t.Errorf("expected inactive token, got %+v", resp)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package golang_oauth
+
+import "github.com/gobeam/golang-oauth/util"
+
+// JWKS builds RFC 7517 JSON Web Key Set of every active and retired public key of given provider,
+// retired keys are kept in the set so tokens issued before rotation can still be verified
+func JWKS(keys KeyProvider) (util.JWKSet, error) {
+	set := util.JWKSet{Keys: []util.JWK{}}
+	for _, key := range keys.Keys() {
+		jwk, err := util.NewJWK(key.Signer.Public(), key.Algorithm, key.ID, "sig")
+		if err != nil {
+			return set, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
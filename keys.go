@@ -0,0 +1,295 @@
+package golang_oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/gobeam/golang-oauth/util"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeyProvider provides keys used to encrypt and sign tokens,
+// implementations parse their keys once so they can be shared by every manager and replica
+type KeyProvider interface {
+	// Key returns active key for given algorithm used to issue new tokens,
+	// util.RS256 key is also used for RSA-OAEP encrypted tokens
+	Key(alg string) (*Key, error)
+
+	// KeyByID returns active or retired key with given id used to verify and decrypt tokens
+	KeyByID(kid string) (*Key, error)
+
+	// Keys returns every active and retired key
+	Keys() []*Key
+}
+
+// Key is a private key with its id, retired keys only verify and decrypt tokens issued before rotation
+type Key struct {
+	ID        string
+	Algorithm string
+	Signer    crypto.Signer
+	CreatedAt time.Time
+	RetiredAt time.Time
+}
+
+// NewKey create key from given *rsa.PrivateKey (RS256) or P-256 *ecdsa.PrivateKey (ES256),
+// key id is RFC 7638 thumbprint of its public key
+func NewKey(signer crypto.Signer) (*Key, error) {
+	var alg string
+	switch key := signer.(type) {
+	case *rsa.PrivateKey:
+		alg = util.RS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New(util.UnsupportedAlgorithm)
+		}
+		alg = util.ES256
+	default:
+		return nil, errors.New(util.UnsupportedAlgorithm)
+	}
+	kid, err := util.JWKThumbprint(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: kid, Algorithm: alg, Signer: signer, CreatedAt: time.Now()}, nil
+}
+
+// GenerateKey generates new key for given algorithm
+func GenerateKey(alg string) (*Key, error) {
+	switch alg {
+	case util.RS256:
+		priv, _ := util.GenerateKeyPair(util.BitSize)
+		return NewKey(priv)
+	case util.ES256:
+		priv, err := util.GenerateECKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(priv)
+	default:
+		return nil, errors.New(util.UnsupportedAlgorithm)
+	}
+}
+
+// Retired reports whether key was rotated out
+func (k *Key) Retired() bool {
+	return !k.RetiredAt.IsZero()
+}
+
+// KeySet KeyProvider holding one active key per algorithm plus retired keys,
+// retired keys keep validating tokens issued before rotation until they are pruned, safe for concurrent use
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []*Key
+}
+
+// verify that KeySet implements KeyProvider
+var _ KeyProvider = (*KeySet)(nil)
+
+// NewKeySet create key set from given keys, last key of each algorithm becomes active
+func NewKeySet(keys ...*Key) *KeySet {
+	set := &KeySet{}
+	for _, key := range keys {
+		set.Add(key)
+	}
+	return set
+}
+
+// NewKeyProvider create key set from keys held in memory,
+// rsaKey is required, ecKey may be nil when ES256 is not used
+func NewKeyProvider(rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) (*KeySet, error) {
+	if rsaKey == nil {
+		return nil, errors.New(util.MissingKey)
+	}
+	key, err := NewKey(rsaKey)
+	if err != nil {
+		return nil, err
+	}
+	set := NewKeySet(key)
+	if ecKey != nil {
+		key, err = NewKey(ecKey)
+		if err != nil {
+			return nil, err
+		}
+		set.Add(key)
+	}
+	return set, nil
+}
+
+// NewPEMKeyProvider create key set from pem encoded keys,
+// rsaPem RSA private key, ecPem P-256 private key (may be empty when ES256 is not used)
+func NewPEMKeyProvider(rsaPem, ecPem []byte) (*KeySet, error) {
+	rsaKey, err := util.ParseRSAPrivateKey(rsaPem)
+	if err != nil {
+		return nil, err
+	}
+	var ecKey *ecdsa.PrivateKey
+	if len(ecPem) > 0 {
+		ecKey, err = util.BytesToECPrivateKey(ecPem)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewKeyProvider(rsaKey, ecKey)
+}
+
+// NewFileKeyProvider create key set from pem files,
+// rsaPath RSA private key file, ecPath P-256 private key file (may be empty when ES256 is not used)
+func NewFileKeyProvider(rsaPath, ecPath string) (*KeySet, error) {
+	rsaPem, err := ioutil.ReadFile(rsaPath)
+	if err != nil {
+		return nil, err
+	}
+	var ecPem []byte
+	if ecPath != "" {
+		ecPem, err = ioutil.ReadFile(ecPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewPEMKeyProvider(rsaPem, ecPem)
+}
+
+// NewEnvKeyProvider create key set from pem encoded keys held in environment variables,
+// rsaEnv variable holding RSA private key, ecEnv variable holding P-256 private key (may be empty when ES256 is not used)
+func NewEnvKeyProvider(rsaEnv, ecEnv string) (*KeySet, error) {
+	rsaPem := os.Getenv(rsaEnv)
+	if rsaPem == "" {
+		return nil, errors.New(util.MissingKey)
+	}
+	var ecPem string
+	if ecEnv != "" {
+		ecPem = os.Getenv(ecEnv)
+		if ecPem == "" {
+			return nil, errors.New(util.MissingKey)
+		}
+	}
+	return NewPEMKeyProvider([]byte(rsaPem), []byte(ecPem))
+}
+
+// NewDefaultKeyProvider create key set from util.PrivatePem and util.ECPrivatePem in working directory,
+// RSA key pair is generated and saved when missing, EC key is loaded only if the file exists,
+// this is what stores use when no KeyProvider is set, failure to save the key pair, for example on
+// read-only filesystem, is returned as error
+func NewDefaultKeyProvider() (*KeySet, error) {
+	if _, err := os.Stat(util.PrivatePem); os.IsNotExist(err) {
+		if err := saveKeyPair(util.PrivatePem, util.PublicPem); err != nil {
+			return nil, err
+		}
+	}
+	ecPath := util.ECPrivatePem
+	if _, err := os.Stat(ecPath); os.IsNotExist(err) {
+		ecPath = ""
+	}
+	return NewFileKeyProvider(util.PrivatePem, ecPath)
+}
+
+// saveKeyPair generates RSA key pair and saves it pem encoded, private key is readable by owner only
+func saveKeyPair(privatePath, publicPath string) error {
+	key, err := rsa.GenerateKey(rand.Reader, util.BitSize)
+	if err != nil {
+		return err
+	}
+	public, err := util.RSAPublicKeyToBytes(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(privatePath, util.RSAPrivateKeyToBytes(key), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(publicPath, public, 0644)
+}
+
+// Add adds key as active key of its algorithm, previously active key of that algorithm is retired,
+// keys which are already retired are added as verification only keys
+func (s *KeySet) Add(key *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !key.Retired() {
+		for i, item := range s.keys {
+			if item.Algorithm == key.Algorithm && !item.Retired() {
+				// keys are replaced instead of modified so callers holding *Key never race with rotation
+				retired := *item
+				retired.RetiredAt = time.Now()
+				s.keys[i] = &retired
+			}
+		}
+	}
+	for i, item := range s.keys {
+		if item.ID == key.ID {
+			s.keys[i] = key
+			return
+		}
+	}
+	s.keys = append(s.keys, key)
+}
+
+// Rotate generates new active key for given algorithm and retires the previous one,
+// replicas must share keys so distribute returned key (or use Add with shared key) to every instance
+func (s *KeySet) Rotate(alg string) (*Key, error) {
+	key, err := GenerateKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	s.Add(key)
+	return key, nil
+}
+
+// Prune removes retired keys which were retired before given time, tokens signed by them stop validating,
+// returns number of removed keys
+func (s *KeySet) Prune(retiredBefore time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.keys[:0]
+	removed := 0
+	for _, item := range s.keys {
+		if item.Retired() && item.RetiredAt.Before(retiredBefore) {
+			removed++
+			continue
+		}
+		keys = append(keys, item)
+	}
+	s.keys = keys
+	return removed
+}
+
+// Key returns active key for given algorithm
+func (s *KeySet) Key(alg string) (*Key, error) {
+	if alg != util.RS256 && alg != util.ES256 {
+		return nil, errors.New(util.UnsupportedAlgorithm)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, item := range s.keys {
+		if item.Algorithm == alg && !item.Retired() {
+			return item, nil
+		}
+	}
+	return nil, errors.New(util.MissingKey)
+}
+
+// KeyByID returns active or retired key with given id
+func (s *KeySet) KeyByID(kid string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, item := range s.keys {
+		if item.ID == kid {
+			return item, nil
+		}
+	}
+	return nil, errors.New(util.UnknownKey)
+}
+
+// Keys returns every active and retired key
+func (s *KeySet) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*Key, len(s.keys))
+	copy(keys, s.keys)
+	return keys
+}
@@ -0,0 +1,222 @@
+package golang_oauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvKeyProvider(t *testing.T) {
+	rsaKey, _ := util.GenerateKeyPair(util.BitSize)
+	ecKey, err := util.GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ecPem, err := util.ECPrivateKeyToBytes(ecKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_ = os.Setenv("TEST_OAUTH_RSA_KEY", string(util.RSAPrivateKeyToBytes(rsaKey)))
+	_ = os.Setenv("TEST_OAUTH_EC_KEY", string(ecPem))
+	defer os.Unsetenv("TEST_OAUTH_RSA_KEY")
+	defer os.Unsetenv("TEST_OAUTH_EC_KEY")
+
+	keys, err := NewEnvKeyProvider("TEST_OAUTH_RSA_KEY", "TEST_OAUTH_EC_KEY")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	key, err := keys.Key(util.RS256)
+	if err != nil || key.Signer.(*rsa.PrivateKey).D.Cmp(rsaKey.D) != 0 {
+		t.Errorf("expected rsa key from environment, got %v", err)
+	}
+	key, err = keys.Key(util.ES256)
+	if err != nil || key.Signer.(*ecdsa.PrivateKey).D.Cmp(ecKey.D) != 0 {
+		t.Errorf("expected ec key from environment, got %v", err)
+	}
+
+	if _, err := NewEnvKeyProvider("TEST_OAUTH_MISSING_KEY", ""); err == nil || err.Error() != util.MissingKey {
+		t.Errorf("expected %q, got %v", util.MissingKey, err)
+	}
+}
+
+func TestSaveKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oauth-keys")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// unwritable location, like read-only filesystem, is reported instead of exiting
+	missing := filepath.Join(dir, "missing")
+	if err := saveKeyPair(filepath.Join(missing, util.PrivatePem), filepath.Join(missing, util.PublicPem)); err == nil {
+		t.Error("expected error saving key pair to missing directory")
+	}
+
+	private, public := filepath.Join(dir, util.PrivatePem), filepath.Join(dir, util.PublicPem)
+	if err := saveKeyPair(private, public); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := NewFileKeyProvider(private, ""); err != nil {
+		t.Error(err.Error())
+	}
+	if info, err := os.Stat(private); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("private key must be readable by owner only, got %v %v", info, err)
+	}
+	if pem, err := ioutil.ReadFile(public); err != nil || util.BytesToPublicKey(pem) == nil {
+		t.Errorf("expected public key, got %v", err)
+	}
+}
+
+func TestSharedKeyProvider(t *testing.T) {
+	rsaKey, _ := util.GenerateKeyPair(util.BitSize)
+	keys, err := NewPEMKeyProvider(util.RSAPrivateKeyToBytes(rsaKey), nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := keys.Key(util.ES256); err == nil || err.Error() != util.MissingKey {
+		t.Errorf("expected %q, got %v", util.MissingKey, err)
+	}
+
+	// tokens issued by one replica are accepted by another sharing the same keys
+	issuer := NewManager(NewDefaultMemoryStore())
+	defer issuer.Close()
+	issuer.SetKeyProvider(keys)
+	replica := NewManager(NewDefaultMemoryStore())
+	defer replica.Close()
+	replica.SetKeyProvider(keys)
+
+	client, err := issuer.CreateClient(userID, "replica app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := issuer.Create(&model.Token{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		UserID:          userID,
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	claims, err := replica.decodeAccessToken(resp.AccessToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if claims.UserId != userID || claims.ClientId != client.ID {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := NewKeySet()
+	first, err := keys.Rotate(util.RS256)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := keys.Rotate(util.ES256); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, format := range []string{util.TokenFormatEncrypted, util.TokenFormatJWT} {
+		store := NewManager(NewDefaultMemoryStore())
+		store.SetKeyProvider(keys)
+		if err := store.SetTokenFormat(format, util.RS256); err != nil {
+			t.Fatal(err.Error())
+		}
+		client, err := store.CreateClient(userID, "rotation app")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		token := &model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			UserID:          userID,
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+		}
+		before, err := store.Create(token)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !strings.HasPrefix(before.AccessToken, first.ID+".") && format == util.TokenFormatEncrypted {
+			t.Errorf("expected encrypted token to start with kid %s", first.ID)
+		}
+
+		second, err := keys.Rotate(util.RS256)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		retired, err := keys.KeyByID(first.ID)
+		if err != nil || !retired.Retired() {
+			t.Fatalf("expected %s to be retired, got %v", first.ID, err)
+		}
+		after, err := store.Create(token)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// tokens issued before rotation keep validating
+		if _, err := store.GetByAccess(before.AccessToken); err != nil {
+			t.Errorf("%s: token issued before rotation: %s", format, err.Error())
+		}
+		if _, err := store.GetByAccess(after.AccessToken); err != nil {
+			t.Errorf("%s: token issued after rotation: %s", format, err.Error())
+		}
+		if _, err := store.GetByRefresh(before.RefreshToken); err != nil {
+			t.Errorf("%s: refresh token issued before rotation: %s", format, err.Error())
+		}
+
+		// pruned keys no longer validate
+		if removed := keys.Prune(time.Now().Add(time.Second)); removed != 1 {
+			t.Errorf("expected 1 pruned key, got %d", removed)
+		}
+		if _, err := store.GetByAccess(before.AccessToken); err == nil {
+			t.Errorf("%s: token of pruned key must not validate", format)
+		}
+		if _, err := store.GetByAccess(after.AccessToken); err != nil {
+			t.Errorf("%s: token of active key: %s", format, err.Error())
+		}
+		first = second
+		store.Close()
+	}
+}
+
+func TestForgedEncryptedToken(t *testing.T) {
+	keys := NewKeySet()
+	key, err := keys.Rotate(util.RS256)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	store := NewManager(NewDefaultMemoryStore())
+	defer store.Close()
+	store.SetKeyProvider(keys)
+
+	// anyone can encrypt with the key published in JWKS, with or without kid prefix
+	payload := []byte(`{"UserId":42,"ClientId":"00000000-0000-0000-0000-000000000001","ExpiredAt":4102444800}`)
+	forged, err := util.EncryptWithPublicKey(payload, key.Signer.Public().(*rsa.PublicKey))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, token := range []string{forged, key.ID + "." + forged} {
+		if _, err := store.decodeAccessToken(token); err == nil || err.Error() != util.InvalidAccessToken {
+			t.Errorf("expected %s for forged token, got %v", util.InvalidAccessToken, err)
+		}
+	}
+	// token without id of its row is not looked up by its other claims
+	sealed, err := store.encrypt(model.AccessTokenPayload{UserId: 42, ClientId: uuid.New(), ExpiredAt: 4102444800})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByAccess(sealed); err == nil || err.Error() != util.InvalidAccessToken {
+		t.Errorf("expected %s for token without id, got %v", util.InvalidAccessToken, err)
+	}
+}
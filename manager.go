@@ -0,0 +1,126 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Manager issues, validates and revokes tokens of every grant on top of TokenStore,
+// it encodes tokens, authenticates clients and enforces grant rules while the store only persists them
+type Manager struct {
+	tokenCodec
+	store TokenStore
+}
+
+// NewManager create token manager backed by given store
+func NewManager(store TokenStore) *Manager {
+	return &Manager{store: store}
+}
+
+// Store returns storage backend of the manager
+func (m *Manager) Store() TokenStore {
+	return m.store
+}
+
+// Close close the store
+func (m *Manager) Close() {
+	m.store.Close()
+}
+
+// Create create and store the new token information for user authenticated by the application,
+// client registered with grant types must be allowed the password grant
+func (m *Manager) Create(info model.TokenInfo) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	if info.GetUserID() == 0 {
+		return tokenResp, errors.New(util.EmptyUserID)
+	}
+
+	//check if valid client
+	_, err := m.authenticateInfo(info, util.GrantPassword)
+	if err != nil {
+		return tokenResp, err
+	}
+	return m.insertTokens(info, uuid.Nil)
+}
+
+// insertTokens creates and stores access token and refresh token for already authenticated client,
+// familyId family of rotated refresh token, uuid.Nil starts new family
+func (m *Manager) insertTokens(info model.TokenInfo, familyId uuid.UUID) (model.TokenResponse, error) {
+	oauthAccess, refreshToken, tokenResp, err := m.newTokens(info, familyId)
+	if err != nil {
+		return tokenResp, err
+	}
+	if err := m.store.InsertAccessToken(*oauthAccess); err != nil {
+		return tokenResp, err
+	}
+	if err := m.store.InsertRefreshToken(*refreshToken); err != nil {
+		return tokenResp, err
+	}
+	return tokenResp, nil
+}
+
+// insertAccessToken creates and stores access token without refresh token for already authenticated client
+func (m *Manager) insertAccessToken(info model.TokenInfo) (model.TokenResponse, error) {
+	oauthAccess, tokenResp, err := m.newAccessToken(info)
+	if err != nil {
+		return tokenResp, err
+	}
+	if err := m.store.InsertAccessToken(*oauthAccess); err != nil {
+		return tokenResp, err
+	}
+	return tokenResp, nil
+}
+
+// GetByAccess use the access token for token information data,
+// access Access token string
+func (m *Manager) GetByAccess(access string) (*model.AccessTokens, error) {
+	accessToken, err := m.decodeAccessToken(access)
+	if err != nil {
+		return nil, err
+	}
+	currentTime := time.Now().Unix()
+	if accessToken.ExpiredAt < currentTime {
+		return nil, errors.New(util.AccessTokenExpired)
+	}
+
+	item, err := m.store.GetAccessToken(accessToken.ID)
+	if err != nil {
+		return nil, err
+	}
+	if item.UserId != accessToken.UserId {
+		return nil, errors.New(util.InvalidAccessToken)
+	}
+	if item.Revoked {
+		return nil, errors.New(util.AccessTokenRevoked)
+	}
+	return &item, nil
+}
+
+// RevokeByAccessTokens revokes token from accessToken, userId 0 is rejected as it would match client credentials tokens
+func (m *Manager) RevokeByAccessTokens(userId int64) error {
+	if userId == 0 {
+		return errors.New(util.EmptyUserID)
+	}
+	return m.store.RevokeByAccessTokens(userId)
+}
+
+// RevokeRefreshToken revokes token from RefreshToken, it is deleted so presenting it later is not taken for reuse
+func (m *Manager) RevokeRefreshToken(accessTokenId string) error {
+	id, err := uuid.Parse(accessTokenId)
+	if err != nil {
+		return nil
+	}
+	return m.store.DeleteRefreshToken(id)
+}
+
+// ClearByAccessToken clears all token related to user,
+// userId id of user whose access token needs to be cleared, 0 is rejected as it would match client credentials tokens
+func (m *Manager) ClearByAccessToken(userId int64) error {
+	if userId == 0 {
+		return errors.New(util.EmptyUserID)
+	}
+	return m.store.ClearByAccessToken(userId)
+}
@@ -0,0 +1,510 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// MemoryStore in-memory token store model, safe for concurrent use
+type MemoryStore struct {
+	mu         sync.RWMutex
+	clients    map[uuid.UUID]model.Clients
+	access     map[uuid.UUID]model.AccessTokens
+	refresh    map[uuid.UUID]model.RefreshTokens
+	refreshIds map[uuid.UUID]uuid.UUID // id of refresh token by id of access token it was issued with
+	codes      map[uuid.UUID]model.AuthCodes
+	devices    map[uuid.UUID]model.DeviceCodes
+	userCodes  map[string]uuid.UUID // id of device authorization by its user code
+	jtis       map[string]int64
+	ticker     *time.Ticker
+}
+
+// verify that MemoryStore implements TokenStore
+var _ TokenStore = (*MemoryStore)(nil)
+
+// NewMemoryStore create in-memory store instance,
+// GC time interval (in seconds, default 600)
+func NewMemoryStore(gcInterval int) *MemoryStore {
+	store := &MemoryStore{
+		clients:    make(map[uuid.UUID]model.Clients),
+		access:     make(map[uuid.UUID]model.AccessTokens),
+		refresh:    make(map[uuid.UUID]model.RefreshTokens),
+		refreshIds: make(map[uuid.UUID]uuid.UUID),
+		codes:      make(map[uuid.UUID]model.AuthCodes),
+		devices:    make(map[uuid.UUID]model.DeviceCodes),
+		userCodes:  make(map[string]uuid.UUID),
+		jtis:       make(map[string]int64),
+	}
+
+	interval := 600
+	if gcInterval > 0 {
+		interval = gcInterval
+	}
+	store.ticker = time.NewTicker(time.Second * time.Duration(interval))
+	go store.gc()
+	return store
+}
+
+// NewDefaultMemoryStore create in-memory store instance with default GC interval
+func NewDefaultMemoryStore() *MemoryStore {
+	return NewMemoryStore(0)
+}
+
+// Close close the store
+func (s *MemoryStore) Close() {
+	s.ticker.Stop()
+}
+
+func (s *MemoryStore) gc() {
+	for range s.ticker.C {
+		_, _ = s.Clean()
+	}
+}
+
+// Clean removes revoked and expired tokens, used and expired authorization codes and device authorizations
+// and expired assertion ids, and reports number of removed items
+func (s *MemoryStore) Clean() (CleanReport, error) {
+	var report CleanReport
+	now := time.Now().Unix()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// expired access token is still needed to refresh it while its refresh token is alive
+	needed := make(map[uuid.UUID]bool)
+	for _, item := range s.refresh {
+		if !item.Revoked && (item.ExpiredAt == 0 || item.ExpiredAt >= now) {
+			needed[item.AccessTokenId] = true
+		}
+	}
+	for id, item := range s.access {
+		if item.Revoked || (item.ExpiredAt < now && !needed[id]) {
+			delete(s.access, id)
+			report.AccessTokens++
+		}
+	}
+	// refresh token whose access token is gone can never be used
+	for id, item := range s.refresh {
+		if _, ok := s.access[item.AccessTokenId]; (!ok && !item.Revoked) || (item.ExpiredAt != 0 && item.ExpiredAt < now) {
+			s.removeRefresh(id)
+			report.RefreshTokens++
+		}
+	}
+	// used refresh tokens are kept while their family is alive to detect their reuse
+	active := make(map[uuid.UUID]bool)
+	for _, item := range s.refresh {
+		if !item.Revoked {
+			active[item.FamilyId] = true
+		}
+	}
+	for id, item := range s.refresh {
+		if item.Revoked && !active[item.FamilyId] {
+			s.removeRefresh(id)
+			report.RefreshTokens++
+		}
+	}
+	for id, item := range s.codes {
+		if item.Revoked || item.ExpiredAt < now {
+			delete(s.codes, id)
+			report.AuthCodes++
+		}
+	}
+	for id, item := range s.devices {
+		if item.Revoked || item.ExpiredAt < now {
+			s.removeDevice(id)
+			report.DeviceCodes++
+		}
+	}
+	for key, expiredAt := range s.jtis {
+		if expiredAt < now {
+			delete(s.jtis, key)
+			report.JTIs++
+		}
+	}
+	return report, nil
+}
+
+// InsertClient stores new client, its secret is already hashed
+func (s *MemoryStore) InsertClient(client model.Clients) error {
+	s.mu.Lock()
+	s.clients[client.ID] = client
+	s.mu.Unlock()
+	return nil
+}
+
+// GetClient returns client of given id
+func (s *MemoryStore) GetClient(clientId uuid.UUID) (model.Clients, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.clients[clientId]
+	if !ok {
+		return client, errors.New(util.InvalidClient)
+	}
+	return client, nil
+}
+
+// UpdateClient saves every field of client except its secrets and registration access token
+func (s *MemoryStore) UpdateClient(client model.Clients) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.clients[client.ID]
+	if !ok {
+		return errors.New(util.InvalidClient)
+	}
+	client.Secret = stored.Secret
+	client.SecretCreatedAt = stored.SecretCreatedAt
+	client.PreviousSecret = stored.PreviousSecret
+	client.PreviousCreatedAt = stored.PreviousCreatedAt
+	client.PreviousExpiredAt = stored.PreviousExpiredAt
+	client.RegistrationToken = stored.RegistrationToken
+	s.clients[client.ID] = client
+	return nil
+}
+
+// UpdateClientSecret saves secret of client and secret it replaced when the stored secret is still current
+func (s *MemoryStore) UpdateClientSecret(client model.Clients, current string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.clients[client.ID]
+	if !ok {
+		return errors.New(util.InvalidClient)
+	}
+	if stored.Secret != current {
+		return errors.New(util.ConcurrentRotation)
+	}
+	stored.Secret = client.Secret
+	stored.SecretCreatedAt = client.SecretCreatedAt
+	stored.PreviousSecret = client.PreviousSecret
+	stored.PreviousCreatedAt = client.PreviousCreatedAt
+	stored.PreviousExpiredAt = client.PreviousExpiredAt
+	stored.UpdatedAt = client.UpdatedAt
+	s.clients[client.ID] = stored
+	return nil
+}
+
+// DeleteClient deletes client together with its access tokens, refresh tokens, authorization codes and device codes
+func (s *MemoryStore) DeleteClient(clientId uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, item := range s.access {
+		if item.ClientId == clientId {
+			s.deleteRefresh(id)
+			delete(s.access, id)
+		}
+	}
+	for id, item := range s.codes {
+		if item.ClientId == clientId {
+			delete(s.codes, id)
+		}
+	}
+	for id, item := range s.devices {
+		if item.ClientId == clientId {
+			s.removeDevice(id)
+		}
+	}
+	delete(s.clients, clientId)
+	return nil
+}
+
+// UseJTI records id of assertion used by client until expiredAt and rejects its replay
+func (s *MemoryStore) UseJTI(clientId uuid.UUID, jti string, expiredAt int64) error {
+	key := clientId.String() + " " + jti
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jtis[key]; ok {
+		return errors.New(util.AssertionReplayed)
+	}
+	s.jtis[key] = expiredAt
+	return nil
+}
+
+// InsertAccessToken stores new access token
+func (s *MemoryStore) InsertAccessToken(access model.AccessTokens) error {
+	s.mu.Lock()
+	s.access[access.ID] = access
+	s.mu.Unlock()
+	return nil
+}
+
+// GetAccessToken returns access token of given id
+func (s *MemoryStore) GetAccessToken(id uuid.UUID) (model.AccessTokens, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.access[id]
+	if !ok {
+		return item, errors.New(util.InvalidAccessToken)
+	}
+	return item, nil
+}
+
+// RevokeAccessToken marks access token of given id revoked
+func (s *MemoryStore) RevokeAccessToken(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.access[id]; ok {
+		item.Revoked = true
+		item.UpdatedAt = time.Now()
+		s.access[id] = item
+	}
+	return nil
+}
+
+// RevokeByAccessTokens marks every access token of given user revoked
+func (s *MemoryStore) RevokeByAccessTokens(userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, item := range s.access {
+		if item.UserId == userId {
+			item.Revoked = true
+			item.UpdatedAt = time.Now()
+			s.access[id] = item
+		}
+	}
+	return nil
+}
+
+// ClearByAccessToken deletes every access token of given user together with its refresh token
+func (s *MemoryStore) ClearByAccessToken(userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, item := range s.access {
+		if item.UserId != userId {
+			continue
+		}
+		s.deleteRefresh(id)
+		delete(s.access, id)
+	}
+	return nil
+}
+
+// InsertRefreshToken stores new refresh token
+func (s *MemoryStore) InsertRefreshToken(refresh model.RefreshTokens) error {
+	s.mu.Lock()
+	s.putRefresh(refresh)
+	s.mu.Unlock()
+	return nil
+}
+
+// GetRefreshToken returns refresh token issued alongside given access token
+func (s *MemoryStore) GetRefreshToken(accessTokenId uuid.UUID) (model.RefreshTokens, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.refreshByAccessId(accessTokenId)
+	if !ok {
+		return item, errors.New(util.InvalidRefreshToken)
+	}
+	return item, nil
+}
+
+// UseRefreshToken marks refresh token of given id revoked, only the call which flips revoked gets true
+func (s *MemoryStore) UseRefreshToken(id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.refresh[id]
+	if !ok || item.Revoked {
+		return false, nil
+	}
+	item.Revoked = true
+	item.UpdatedAt = time.Now()
+	s.refresh[id] = item
+	return true, nil
+}
+
+// RevokeRefreshFamily marks every refresh token of given family and their access tokens revoked
+// and returns those access tokens
+func (s *MemoryStore) RevokeRefreshFamily(familyId uuid.UUID) ([]model.AccessTokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var accessTokens []model.AccessTokens
+	for id, item := range s.refresh {
+		if item.FamilyId != familyId {
+			continue
+		}
+		item.Revoked = true
+		item.UpdatedAt = time.Now()
+		s.refresh[id] = item
+		if access, ok := s.access[item.AccessTokenId]; ok {
+			access.Revoked = true
+			access.UpdatedAt = time.Now()
+			s.access[access.ID] = access
+			accessTokens = append(accessTokens, access)
+		}
+	}
+	return accessTokens, nil
+}
+
+// DeleteRefreshToken deletes refresh token issued alongside given access token
+func (s *MemoryStore) DeleteRefreshToken(accessTokenId uuid.UUID) error {
+	s.mu.Lock()
+	s.deleteRefresh(accessTokenId)
+	s.mu.Unlock()
+	return nil
+}
+
+// InsertAuthCode stores new authorization code
+func (s *MemoryStore) InsertAuthCode(code model.AuthCodes) error {
+	s.mu.Lock()
+	s.codes[code.ID] = code
+	s.mu.Unlock()
+	return nil
+}
+
+// GetAuthCode returns authorization code of given id
+func (s *MemoryStore) GetAuthCode(id uuid.UUID) (model.AuthCodes, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.codes[id]
+	if !ok {
+		return item, errors.New(util.InvalidAuthCode)
+	}
+	return item, nil
+}
+
+// UseAuthCode marks authorization code of given id revoked, only the call which flips revoked gets true
+func (s *MemoryStore) UseAuthCode(id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.codes[id]
+	if !ok || item.Revoked {
+		return false, nil
+	}
+	item.Revoked = true
+	item.UpdatedAt = time.Now()
+	s.codes[id] = item
+	return true, nil
+}
+
+// InsertDeviceCode stores new device authorization, util.UserCodeInUse when its user code is taken
+func (s *MemoryStore) InsertDeviceCode(code model.DeviceCodes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deviceByUserCode(code.UserCode); ok {
+		return errors.New(util.UserCodeInUse)
+	}
+	s.devices[code.ID] = code
+	s.userCodes[code.UserCode] = code.ID
+	return nil
+}
+
+// GetDeviceCode returns device authorization of given id
+func (s *MemoryStore) GetDeviceCode(id uuid.UUID) (model.DeviceCodes, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.devices[id]
+	if !ok {
+		return item, errors.New(util.InvalidDeviceCode)
+	}
+	return item, nil
+}
+
+// GetDeviceCodeByUserCode returns device authorization of given normalized user code
+func (s *MemoryStore) GetDeviceCodeByUserCode(userCode string) (model.DeviceCodes, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.deviceByUserCode(userCode)
+	if !ok {
+		return item, errors.New(util.InvalidUserCode)
+	}
+	return item, nil
+}
+
+// ApproveDeviceCode approves pending device authorization of given normalized user code,
+// userId id of logged in user tokens are issued for
+func (s *MemoryStore) ApproveDeviceCode(userCode string, userId int64) error {
+	return s.decideDeviceCode(userCode, func(code *model.DeviceCodes) {
+		code.Approved = true
+		code.UserId = userId
+	})
+}
+
+// DenyDeviceCode denies pending device authorization of given normalized user code
+func (s *MemoryStore) DenyDeviceCode(userCode string) error {
+	return s.decideDeviceCode(userCode, func(code *model.DeviceCodes) {
+		code.Denied = true
+	})
+}
+
+// decideDeviceCode applies approval or denial to device authorization, only pending one may be decided
+func (s *MemoryStore) decideDeviceCode(userCode string, decide func(code *model.DeviceCodes)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deviceCode, ok := s.deviceByUserCode(userCode)
+	if !ok || !pendingDeviceCode(deviceCode) {
+		return errors.New(util.InvalidUserCode)
+	}
+	decide(&deviceCode)
+	deviceCode.UpdatedAt = time.Now()
+	s.devices[deviceCode.ID] = deviceCode
+	return nil
+}
+
+// UpdateDeviceCodePoll saves last poll time and poll interval of device authorization
+func (s *MemoryStore) UpdateDeviceCodePoll(code model.DeviceCodes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.devices[code.ID]; ok {
+		item.LastPolledAt = code.LastPolledAt
+		item.PollInterval = code.PollInterval
+		item.UpdatedAt = code.UpdatedAt
+		s.devices[code.ID] = item
+	}
+	return nil
+}
+
+// UseDeviceCode marks device authorization of given id revoked, only the call which flips revoked gets true
+func (s *MemoryStore) UseDeviceCode(id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.devices[id]
+	if !ok || item.Revoked {
+		return false, nil
+	}
+	item.Revoked = true
+	item.UpdatedAt = time.Now()
+	s.devices[id] = item
+	return true, nil
+}
+
+// deviceByUserCode finds device authorization of given normalized user code, caller must hold the lock
+func (s *MemoryStore) deviceByUserCode(userCode string) (model.DeviceCodes, bool) {
+	item, ok := s.devices[s.userCodes[userCode]]
+	return item, ok
+}
+
+// removeDevice deletes device authorization together with its user code, caller must hold the lock
+func (s *MemoryStore) removeDevice(id uuid.UUID) {
+	if item, ok := s.devices[id]; ok && s.userCodes[item.UserCode] == id {
+		delete(s.userCodes, item.UserCode)
+	}
+	delete(s.devices, id)
+}
+
+// refreshByAccessId finds refresh token issued alongside given access token, caller must hold the lock
+func (s *MemoryStore) refreshByAccessId(accessTokenId uuid.UUID) (model.RefreshTokens, bool) {
+	item, ok := s.refresh[s.refreshIds[accessTokenId]]
+	return item, ok
+}
+
+// putRefresh stores refresh token and indexes it by its access token, caller must hold the lock
+func (s *MemoryStore) putRefresh(token model.RefreshTokens) {
+	s.refresh[token.ID] = token
+	s.refreshIds[token.AccessTokenId] = token.ID
+}
+
+// removeRefresh deletes refresh token together with its index entry, caller must hold the lock
+func (s *MemoryStore) removeRefresh(id uuid.UUID) {
+	if item, ok := s.refresh[id]; ok && s.refreshIds[item.AccessTokenId] == id {
+		delete(s.refreshIds, item.AccessTokenId)
+	}
+	delete(s.refresh, id)
+}
+
+// deleteRefresh deletes refresh token issued alongside given access token, caller must hold the lock,
+// revoked refresh token would be taken for reuse of rotated one when presented later
+func (s *MemoryStore) deleteRefresh(accessTokenId uuid.UUID) {
+	if id, ok := s.refreshIds[accessTokenId]; ok {
+		s.removeRefresh(id)
+	}
+}
@@ -0,0 +1,108 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreRefreshOneTimeUse(t *testing.T) {
+	backend := NewDefaultMemoryStore()
+	store := NewManager(backend)
+	defer store.Close()
+	client, err := store.CreateClient(userID, "memory app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := store.Create(&model.Token{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		UserID:          userID,
+		Scope:           "*",
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByRefresh(resp.RefreshToken); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByRefresh(resp.RefreshToken); err == nil || err.Error() != util.RefreshTokenRevoked {
+		t.Errorf("expected %q, got %v", util.RefreshTokenRevoked, err)
+	}
+	if _, err := store.GetByAccess(resp.AccessToken); err == nil || err.Error() != util.AccessTokenRevoked {
+		t.Errorf("expected %q, got %v", util.AccessTokenRevoked, err)
+	}
+
+	if _, err := backend.Clean(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(backend.access) != 0 || len(backend.refresh) != 0 || len(backend.refreshIds) != 0 {
+		t.Errorf("expected revoked tokens to be cleaned, got %d access and %d refresh", len(backend.access), len(backend.refresh))
+	}
+}
+
+func TestMemoryStoreConcurrentCreate(t *testing.T) {
+	backend := NewDefaultMemoryStore()
+	store := NewManager(backend)
+	defer store.Close()
+	client, err := store.CreateClient(userID, "memory app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.Create(&model.Token{
+				ClientID:        client.ID,
+				ClientSecret:    client.Secret,
+				UserID:          int64(i + 1),
+				AccessCreateAt:  time.Now(),
+				AccessExpiresIn: time.Minute,
+			})
+			if err != nil {
+				t.Error(err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(backend.access) != 10 {
+		t.Errorf("expected 10 access tokens, got %d", len(backend.access))
+	}
+}
+
+func TestMemoryStoreDeleteRegisteredClient(t *testing.T) {
+	backend := NewDefaultMemoryStore()
+	store := NewManager(backend)
+	defer store.Close()
+	registration, err := store.RegisterClient(userID, model.ClientMetadata{
+		GrantTypes: []string{util.GrantPassword, util.GrantRefreshToken},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	clientId := uuid.MustParse(registration.ClientId)
+	_, err = store.Create(&model.Token{
+		ClientID:        clientId,
+		ClientSecret:    registration.ClientSecret,
+		UserID:          userID,
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.DeleteRegisteredClient(clientId, registration.RegistrationAccessToken); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(backend.clients) != 0 || len(backend.access) != 0 || len(backend.refresh) != 0 || len(backend.refreshIds) != 0 {
+		t.Errorf("expected client and its tokens to be deleted, got %d clients, %d access and %d refresh",
+			len(backend.clients), len(backend.access), len(backend.refresh))
+	}
+}
@@ -0,0 +1,37 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+)
+
+// Metadata returns server metadata (RFC 8414 2) of what the manager supports: issuer, client authentication methods,
+// PKCE methods, ID token signing algorithms and registrable scopes, endpoints and grant types are left to the server exposing the manager
+func (c *tokenCodec) Metadata() model.ServerMetadata {
+	metadata := model.ServerMetadata{
+		Issuer:                        c.issuer,
+		ResponseTypesSupported:        []string{},
+		CodeChallengeMethodsSupported: []string{util.PKCES256, util.PKCEPlain},
+	}
+	authMethods := []string{util.AuthMethodBasic, util.AuthMethodPost}
+	// client assertions are rejected until their audience is configured
+	if len(c.audience) > 0 {
+		authMethods = append(authMethods, util.AuthMethodPrivateKey)
+		metadata.TokenEndpointAuthSigningAlgValuesSupported = []string{util.RS256, util.ES256}
+	}
+	// public clients authenticate at token and revocation endpoints by client_id only
+	metadata.IntrospectionEndpointAuthMethodsSupported = authMethods
+	metadata.TokenEndpointAuthMethodsSupported = append(authMethods[:len(authMethods):len(authMethods)], util.AuthMethodNone)
+	metadata.RevocationEndpointAuthMethodsSupported = metadata.TokenEndpointAuthMethodsSupported
+
+	if c.issuer != "" {
+		metadata.ScopesSupported = []string{util.ScopeOpenID}
+		if c.claims != nil {
+			metadata.ScopesSupported = append(metadata.ScopesSupported, "profile", "email", "address", "phone")
+		}
+		metadata.SubjectTypesSupported = []string{util.SubjectTypePublic}
+		metadata.IDTokenSigningAlgValuesSupported = c.idTokenAlgorithms()
+	}
+	metadata.ScopesSupported = append(metadata.ScopesSupported, c.scopes...)
+	return metadata
+}
@@ -1,14 +1,38 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+)
 
 // AccessTokens is model for Oauth Access Token
 type AccessTokens struct {
 	Model
 	AccessTokenPayload
-	Name    string `db:"name"`
-	Scope   string `db:"scope"`
-	Revoked bool   `db:"revoked"`
+	Name     string `db:"name"`
+	Scope    string `db:"scope"`
+	Audience string `db:"audience"` // service token is aimed at, set by token exchange
+	Act      string `db:"act"`      // json encoded actor chain of delegated token, see Actor
+	Revoked  bool   `db:"revoked"`
+}
+
+// Actor is actor of delegated token (RFC 8693 4.1), Act is the previous actor in the chain
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientId string `json:"client_id,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
+}
+
+// Actor decodes actor chain of token, nil when token was not delegated
+func (a AccessTokens) Actor() (*Actor, error) {
+	if a.Act == "" {
+		return nil, nil
+	}
+	var actor Actor
+	if err := json.Unmarshal([]byte(a.Act), &actor); err != nil {
+		return nil, err
+	}
+	return &actor, nil
 }
 
 // AccessTokenPayload is data that will be encrypted by RSA encryption
@@ -17,3 +41,15 @@ type AccessTokenPayload struct {
 	ClientId  uuid.UUID `db:"client_id"`
 	ExpiredAt int64     `db:"expired_at"`
 }
+
+// AccessTokenClaims is payload of signed JWT access token (RFC 7519)
+type AccessTokenClaims struct {
+	Subject   string `json:"sub"`
+	ClientId  string `json:"client_id"`
+	Scope     string `json:"scope,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	Act       *Actor `json:"act,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+	ID        string `json:"jti"`
+}
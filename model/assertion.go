@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+)
+
+// AssertionClaims are claims of JWT used as client assertion or authorization grant (RFC 7523 3)
+type AssertionClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti"`
+}
+
+// Audience is aud claim which may be single string or array of strings
+type Audience []string
+
+// UnmarshalJSON decodes aud claim of either form
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Contains reports whether audience contains one of given values
+func (a Audience) Contains(values ...string) bool {
+	for _, item := range a {
+		for _, value := range values {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UsedJTIs is model for ids of assertions already used by client, kept until assertion expires to prevent replay
+type UsedJTIs struct {
+	Model
+	ClientId  uuid.UUID `db:"client_id"`
+	JTI       string    `db:"jti"`
+	ExpiredAt int64     `db:"expired_at"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuthCodes is model for oauth authorization codes
+type AuthCodes struct {
+	Model
+	UserId              int64     `db:"user_id"`
+	ClientId            uuid.UUID `db:"client_id"`
+	RedirectURI         string    `db:"redirect_uri"`
+	Scope               string    `db:"scope"`
+	CodeChallenge       string    `db:"code_challenge"`
+	CodeChallengeMethod string    `db:"code_challenge_method"`
+	Nonce               string    `db:"nonce"`
+	AuthTime            int64     `db:"auth_time"`
+	ExpiredAt           int64     `db:"expired_at"`
+	Revoked             bool      `db:"revoked"`
+}
+
+// AuthCodePayload is data that will be encrypted by RSA encryption into authorization code
+type AuthCodePayload struct {
+	AuthCodeId uuid.UUID
+}
+
+// AuthCodeRequest is authorization request approved by resource owner (RFC 6749 4.1.1),
+// CodeChallenge and CodeChallengeMethod are PKCE parameters (RFC 7636),
+// Nonce and AuthTime are put in ID token when openid scope is requested (OpenID Connect Core 3.1.2.1)
+type AuthCodeRequest struct {
+	ClientID            uuid.UUID
+	UserID              int64
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	AuthTime            time.Time     // time the user authenticated, default time of the request
+	ExpiresIn           time.Duration // lifetime of code, default util.AuthCodeExpiry seconds
+}
@@ -1,10 +1,68 @@
 package model
 
+import (
+	"github.com/google/uuid"
+	"strings"
+)
+
 // Clients is model for oauth clients
 type Clients struct {
 	Model
-	UserId  int64  `db:"user_id"`
-	Name    string `db:"name"`
-	Secret  string `db:"secret"`
-	Revoked bool   `db:"revoked"`
+	UserId               int64  `db:"user_id"`
+	Name                 string `db:"name"`
+	Secret               string `db:"secret"`                 // bcrypt hash of secret, plain text only on client returned at creation
+	SecretCreatedAt      int64  `db:"secret_created_at"`      // unix time secret was issued
+	PreviousSecret       string `db:"previous_secret"`        // bcrypt hash of secret replaced by rotation, valid until PreviousExpiredAt
+	PreviousCreatedAt    int64  `db:"previous_created_at"`    // unix time previous secret was issued
+	PreviousExpiredAt    int64  `db:"previous_expired_at"`    // unix time previous secret stops being accepted
+	Redirect             string `db:"redirect"`               // space separated redirect uris
+	Public               bool   `db:"public"`                 // public clients have no secret and must use PKCE
+	Scope                string `db:"scope"`                  // space separated scopes of tokens issued to client itself, * allows any
+	JWKS                 string `db:"jwks"`                   // json encoded JWK Set of client public keys used to verify its assertions
+	AccessTokenLifetime  int64  `db:"access_token_lifetime"`  // default access token lifetime in seconds, 0 uses util.AccessTokenExpiry
+	RefreshTokenLifetime int64  `db:"refresh_token_lifetime"` // default refresh token lifetime in seconds, 0 uses util.RefreshTokenExpiry
+	GrantTypes           string `db:"grant_types"`            // space separated grant types client may use, empty allows any
+	AuthMethod           string `db:"auth_method"`            // token endpoint authentication method client registered with
+	RegistrationToken    string `db:"registration_token"`     // hash of registration access token of dynamically registered client
+	IDTokenAlg           string `db:"id_token_alg"`           // algorithm ID tokens of client are signed with, empty uses util.RS256
+	Revoked              bool   `db:"revoked"`
+}
+
+// HasRedirect reports whether given redirect uri is registered for client, uris are compared exactly
+func (c Clients) HasRedirect(redirectURI string) bool {
+	for _, item := range strings.Fields(c.Redirect) {
+		if item == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsGrant reports whether client may use given grant type, clients without registered grant types may use any
+func (c Clients) AllowsGrant(grantType string) bool {
+	if c.GrantTypes == "" {
+		return true
+	}
+	for _, item := range strings.Fields(c.GrantTypes) {
+		if item == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsAuthMethod reports whether client may authenticate with given token endpoint authentication method,
+// clients without registered method may use any
+func (c Clients) AcceptsAuthMethod(method string) bool {
+	return c.AuthMethod == "" || c.AuthMethod == method
+}
+
+// ClientCredentials are credentials client authenticates with, secret or client assertion (RFC 7523 2.2),
+// ClientID may be uuid.Nil with assertion as the assertion identifies the client
+type ClientCredentials struct {
+	ClientID            uuid.UUID
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	AuthMethod          string // client_secret_basic or client_secret_post the secret was sent with, empty accepts either
 }
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// DeviceCodes is model for pending device authorizations (RFC 8628)
+type DeviceCodes struct {
+	Model
+	ClientId     uuid.UUID `db:"client_id"`
+	UserCode     string    `db:"user_code"`
+	Scope        string    `db:"scope"`
+	UserId       int64     `db:"user_id"` // user who approved the request, 0 while pending
+	Approved     bool      `db:"approved"`
+	Denied       bool      `db:"denied"`
+	PollInterval int64     `db:"poll_interval"` // minimum seconds between polls
+	LastPolledAt int64     `db:"last_polled_at"`
+	ExpiredAt    int64     `db:"expired_at"`
+	Revoked      bool      `db:"revoked"`
+}
+
+// DeviceCodePayload is data that will be encrypted by RSA encryption into device code
+type DeviceCodePayload struct {
+	DeviceCodeId uuid.UUID
+}
+
+// DeviceCodeRequest is device authorization request of client (RFC 8628 3.1),
+// ClientSecret is empty for public clients and clients authenticating with ClientAssertion
+type DeviceCodeRequest struct {
+	ClientID            uuid.UUID
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	AuthMethod          string // authentication method ClientSecret was sent with, empty accepts either
+	Scope               string
+	VerificationURI     string        // page where user enters user code
+	ExpiresIn           time.Duration // lifetime of codes, default util.DeviceCodeExpiry seconds
+	Interval            time.Duration // minimum time between polls, default util.DeviceCodeInterval seconds
+}
+
+// DeviceCodeResponse is device authorization response (RFC 8628 3.2)
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
@@ -0,0 +1,7 @@
+package model
+
+// ErrorResponse is body of OAuth error response (RFC 6749 5.2)
+type ErrorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// SecurityEvent is emitted by Manager when it detects likely token theft
+type SecurityEvent struct {
+	Type      string // util.EventRefreshTokenReuse
+	UserId    int64
+	ClientId  uuid.UUID
+	FamilyId  uuid.UUID // refresh token family which was revoked
+	CreatedAt time.Time
+}
@@ -0,0 +1,10 @@
+package model
+
+import "github.com/google/uuid"
+
+// GCLeases is lease of garbage collection leadership, the store instance Holder runs cleanup until ExpiredAt
+type GCLeases struct {
+	Name      string    `db:"name,primarykey"`
+	Holder    uuid.UUID `db:"holder"`
+	ExpiredAt int64     `db:"expired_at"`
+}
@@ -0,0 +1,15 @@
+package model
+
+// IntrospectionResponse is state of token reported to resource servers (RFC 7662 2.2),
+// inactive token carries nothing but Active so nothing is revealed about it
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Act       *Actor `json:"act,omitempty"`
+}
@@ -5,11 +5,13 @@ import "github.com/google/uuid"
 // RefreshTokenPayload is model for oauth refresh token
 type RefreshTokenPayload struct {
 	AccessTokenId uuid.UUID `db:"access_token_id"`
+	ExpiredAt     int64     `db:"expired_at"` // 0 for tokens issued before refresh tokens expired
 }
 
 // RefreshTokens is model for oauth refresh token
 type RefreshTokens struct {
 	Model
 	RefreshTokenPayload
-	Revoked bool `db:"revoked"`
+	FamilyId uuid.UUID `db:"family_id"` // shared by refresh tokens rotated from the same grant
+	Revoked  bool      `db:"revoked"`
 }
@@ -0,0 +1,27 @@
+package model
+
+import "github.com/gobeam/golang-oauth/util"
+
+// ClientMetadata is metadata client registers itself with (RFC 7591 2), omitted grant types default to
+// authorization_code and omitted authentication method to client_secret_basic
+type ClientMetadata struct {
+	RedirectURIs             []string     `json:"redirect_uris,omitempty"`
+	GrantTypes               []string     `json:"grant_types,omitempty"`
+	TokenEndpointAuthMethod  string       `json:"token_endpoint_auth_method,omitempty"`
+	Scope                    string       `json:"scope,omitempty"`
+	ClientName               string       `json:"client_name,omitempty"`
+	JWKS                     *util.JWKSet `json:"jwks,omitempty"`
+	IDTokenSignedResponseAlg string       `json:"id_token_signed_response_alg,omitempty"` // defaults to RS256
+}
+
+// ClientRegistration is client information response (RFC 7591 3.2.1, RFC 7592 3), ClientSecret and
+// RegistrationAccessToken are returned only when they are issued as the store keeps their hashes only
+type ClientRegistration struct {
+	ClientMetadata
+	ClientId                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIdIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   *int64 `json:"client_secret_expires_at,omitempty"` // 0 when secret never expires
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+}
@@ -7,22 +7,29 @@ import (
 
 // TokenResponse model after creating access token and refresh token
 type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiredAt    int64  `json:"expired_at"`
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiredAt        int64  `json:"expired_at"`
+	RefreshExpiredAt int64  `json:"refresh_expired_at,omitempty"`
+	Scope            string `json:"scope,omitempty"`             // granted scope
+	IssuedTokenType  string `json:"issued_token_type,omitempty"` // set by token exchange only
+	IDToken          string `json:"id_token,omitempty"`          // set for openid scope only
 }
 
 // Token struct which hold token details
 type Token struct {
-	ClientID         uuid.UUID     `bson:"ClientID"`
-	ClientSecret     string        `bson:"ClientSecret"`
-	UserID           int64         `bson:"UserID"`
-	RedirectURI      string        `bson:"RedirectURI"`
-	Scope            string        `bson:"Scope"`
-	AccessCreateAt   time.Time     `bson:"AccessCreateAt"`
-	AccessExpiresIn  time.Duration `bson:"AccessExpiresIn"`
-	RefreshCreateAt  time.Time     `bson:"RefreshCreateAt"`
-	RefreshExpiresIn time.Duration `bson:"RefreshExpiresIn"`
+	ClientID            uuid.UUID     `bson:"ClientID"`
+	ClientSecret        string        `bson:"ClientSecret"`
+	ClientAssertionType string        `bson:"ClientAssertionType"`
+	ClientAssertion     string        `bson:"ClientAssertion"`
+	AuthMethod          string        `bson:"AuthMethod"`
+	UserID              int64         `bson:"UserID"`
+	RedirectURI         string        `bson:"RedirectURI"`
+	Scope               string        `bson:"Scope"`
+	AccessCreateAt      time.Time     `bson:"AccessCreateAt"`
+	AccessExpiresIn     time.Duration `bson:"AccessExpiresIn"`
+	RefreshCreateAt     time.Time     `bson:"RefreshCreateAt"`
+	RefreshExpiresIn    time.Duration `bson:"RefreshExpiresIn"`
 }
 
 // TokenInfo the token information model interface
@@ -48,6 +55,18 @@ type TokenInfo interface {
 	SetRefreshExpiresIn(time.Duration)
 }
 
+// ClientAssertionInfo is implemented by TokenInfo which can carry client assertion (RFC 7523 2.2) instead of client secret
+type ClientAssertionInfo interface {
+	GetClientAssertionType() string
+	GetClientAssertion() string
+}
+
+// AuthMethodInfo is implemented by TokenInfo which records how client secret was sent,
+// util.AuthMethodBasic or util.AuthMethodPost, empty accepts either
+type AuthMethodInfo interface {
+	GetAuthMethod() string
+}
+
 // NewToken create to token model instance
 func NewToken() *Token {
 	return &Token{}
@@ -73,6 +92,21 @@ func (t *Token) SetClientSecret() string {
 	return t.ClientSecret
 }
 
+// GetClientAssertionType type of client assertion
+func (t *Token) GetClientAssertionType() string {
+	return t.ClientAssertionType
+}
+
+// GetClientAssertion signed JWT client authenticates with instead of secret
+func (t *Token) GetClientAssertion() string {
+	return t.ClientAssertion
+}
+
+// GetAuthMethod authentication method client secret was sent with
+func (t *Token) GetAuthMethod() string {
+	return t.AuthMethod
+}
+
 // SetClientID the client id
 func (t *Token) SetClientID(clientID uuid.UUID) {
 	t.ClientID = clientID
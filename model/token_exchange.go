@@ -0,0 +1,24 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TokenExchangeRequest is token exchange request (RFC 8693 2.1) of client swapping subject token for a new token,
+// ActorToken is set for delegation and empty for impersonation
+type TokenExchangeRequest struct {
+	ClientID            uuid.UUID
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	AuthMethod          string // authentication method ClientSecret was sent with, empty accepts either
+	SubjectToken        string
+	SubjectTokenType    string
+	ActorToken          string
+	ActorTokenType      string
+	Scope               string        // must be within scope of subject token, empty keeps it
+	Audience            string        // service issued token is aimed at
+	AccessCreateAt      time.Time     // zero defaults to now
+	AccessExpiresIn     time.Duration // capped at expiry of subject token, zero keeps it
+}
@@ -1,46 +1,59 @@
 package golang_oauth
 
 import (
-	"bytes"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql" //mysql driver for NewStore
 	"github.com/gobeam/golang-oauth/model"
 	"github.com/gobeam/golang-oauth/util"
 	"github.com/google/uuid"
-	"github.com/json-iterator/go"
 	"gopkg.in/gorp.v2"
 	"io"
-	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// Store mysql token store model
+// Store sql token store model, backed by mysql, postgres or sqlite
 type Store struct {
-	clientTable  string
-	accessTable  string
-	refreshTable string
-	db           *gorp.DbMap
-	stdout       io.Writer
-	ticker       *time.Ticker
+	clientTable     string
+	accessTable     string
+	refreshTable    string
+	authCodeTable   string
+	deviceCodeTable string
+	jtiTable        string
+	leaseTable      string
+	gcBatchSize     int
+	gcInterval      int64
+	instanceId      uuid.UUID
+	election        int32
+	leader          int32
+	db              *gorp.DbMap
+	stdout          io.Writer
+	ticker          *time.Ticker
 }
 
-// Config mysql configuration
+// Config sql database configuration
 type Config struct {
 	DSN          string
+	Driver       string // database/sql driver name, util.DriverMySQL (default), util.DriverPostgres or util.DriverSQLite
 	MaxLifetime  time.Duration
 	MaxOpenConns int
 	MaxIdleConns int
 }
 
-// NewStore create mysql store instance,
-// config mysql configuration,
+// NewStore create sql store instance,
+// config database configuration, Driver selects the backend (mysql by default),
 // GC time interval (in seconds, default 600)
 func NewStore(config *Config, gcInterval int) *Store {
-	db, err := sql.Open("mysql", config.DSN)
+	driver := config.Driver
+	if driver == "" {
+		driver = util.DriverMySQL
+	}
+	db, err := sql.Open(driver, config.DSN)
 	if err != nil {
 		panic(err)
 	}
@@ -48,35 +61,67 @@ func NewStore(config *Config, gcInterval int) *Store {
 	db.SetMaxOpenConns(config.MaxOpenConns)
 	db.SetMaxIdleConns(config.MaxIdleConns)
 	db.SetConnMaxLifetime(config.MaxLifetime)
-	return NewStoreWithDB(db, gcInterval)
+	return NewStoreWithDialect(db, dialectFor(driver), gcInterval)
 }
 
 // NewStoreWithDB create mysql store instance,
 // db sql.DB,
 // GC time interval (in seconds, default 600)
 func NewStoreWithDB(db *sql.DB, gcInterval int) *Store {
-	store := &Store{
-		db:           &gorp.DbMap{Db: db, Dialect: gorp.MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"}},
-		accessTable:  util.AccessTokenTable,
-		clientTable:  util.ClientTable,
-		refreshTable: util.RefreshTokenTable,
-		stdout:       os.Stderr,
-	}
+	return NewStoreWithDialect(db, dialectFor(util.DriverMySQL), gcInterval)
+}
 
-	store.db.AddTableWithName(model.AccessTokens{}, store.accessTable)
-	store.db.AddTableWithName(model.Clients{}, store.clientTable)
+// NewStoreWithDialect create sql store instance,
+// db sql.DB,
+// dialect gorp dialect matching the database behind db,
+// GC time interval (in seconds, default 600)
+func NewStoreWithDialect(db *sql.DB, dialect gorp.Dialect, gcInterval int) *Store {
+	store := &Store{
+		db:              &gorp.DbMap{Db: db, Dialect: dialect},
+		accessTable:     util.AccessTokenTable,
+		clientTable:     util.ClientTable,
+		refreshTable:    util.RefreshTokenTable,
+		authCodeTable:   util.AuthCodeTable,
+		deviceCodeTable: util.DeviceCodeTable,
+		jtiTable:        util.JTITable,
+		leaseTable:      util.GCLeaseTable,
+		gcBatchSize:     util.GCBatchSize,
+		instanceId:      uuid.New(),
+		stdout:          os.Stderr,
+	}
+	if _, ok := dialect.(PostgresDialect); ok {
+		store.db.TypeConverter = postgresTypeConverter{}
+	}
+
+	store.db.AddTableWithName(model.AccessTokens{}, store.accessTable).ColMap("act").SetMaxSize(1000)
+	clientTable := store.db.AddTableWithName(model.Clients{}, store.clientTable)
+	clientTable.ColMap("redirect").SetMaxSize(2000)
+	clientTable.ColMap("scope").SetMaxSize(1000)
+	clientTable.ColMap("jwks").SetMaxSize(4000)
+	clientTable.ColMap("grant_types").SetMaxSize(1000)
 	store.db.AddTableWithName(model.RefreshTokens{}, store.refreshTable)
+	authCodes := store.db.AddTableWithName(model.AuthCodes{}, store.authCodeTable)
+	authCodes.ColMap("redirect_uri").SetMaxSize(2000)
+	authCodes.ColMap("nonce").SetMaxSize(255)
+	store.db.AddTableWithName(model.DeviceCodes{}, store.deviceCodeTable).ColMap("user_code").SetMaxSize(16).SetUnique(true)
+	store.db.AddTableWithName(model.UsedJTIs{}, store.jtiTable).SetUniqueTogether("client_id", "jti")
+	store.db.AddTableWithName(model.GCLeases{}, store.leaseTable).ColMap("name").SetMaxSize(64)
 
 	err := store.db.CreateTablesIfNotExists()
 	if err != nil {
 		panic(err)
 	}
+	err = store.migrate()
+	if err != nil {
+		panic(err)
+	}
 	_ = store.db.CreateIndex()
 
 	interval := 600
 	if gcInterval > 0 {
 		interval = gcInterval
 	}
+	store.gcInterval = int64(interval)
 	store.ticker = time.NewTicker(time.Second * time.Duration(interval))
 	go store.gc()
 	return store
@@ -87,323 +132,602 @@ func NewStoreWithDB(db *sql.DB, gcInterval int) *Store {
 func NewConfig(dsn string) *Config {
 	return &Config{
 		DSN:          dsn,
+		Driver:       util.DriverMySQL,
 		MaxLifetime:  time.Hour * 2,
 		MaxOpenConns: 50,
 		MaxIdleConns: 25,
 	}
 }
 
-// NewDefaultStore create mysql store instance,
-// config mysql configuration,
+// NewDefaultStore create sql store instance,
+// config database configuration,
 func NewDefaultStore(config *Config) *Store {
 	return NewStore(config, 0)
 }
 
-// Close close the store
-func (s *Store) Close() {
-	s.ticker.Stop()
-	_ = s.db.Db.Close()
+// dialectFor returns gorp dialect for given database/sql driver name
+func dialectFor(driver string) gorp.Dialect {
+	switch driver {
+	case util.DriverPostgres:
+		return PostgresDialect{}
+	case util.DriverSQLite:
+		return gorp.SqliteDialect{}
+	default:
+		return gorp.MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"}
+	}
 }
 
-func (s *Store) gc() {
-	for range s.ticker.C {
-		s.clean()
+// rebind replaces ? placeholders in query with bind variables of store dialect
+func (s *Store) rebind(query string) string {
+	var buf strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			buf.WriteString(s.db.Dialect.BindVar(n))
+			n++
+			continue
+		}
+		buf.WriteRune(r)
 	}
+	return buf.String()
 }
 
-// clean is method to clean expired and revoked access token and refresh token during creation of mysql store instance
-func (s *Store) clean() {
-	now := time.Now().Unix()
-	_, accessErr := s.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE (revoked='1')", s.accessTable))
-	_, refreshErr := s.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE revoked='1'", s.refreshTable), now)
-	if accessErr != nil {
-		s.errorf(accessErr.Error())
+// migrate adds columns introduced after tables were first created,
+// tables created by this version of the store already have them
+func (s *Store) migrate() error {
+	if err := s.addColumn(s.clientTable, "redirect", "", 2000, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "public", false, 0, "false"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "scope", "", 1000, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "jwks", "", 4000, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "access_token_lifetime", int64(0), 0, "0"); err != nil {
+		return err
 	}
-	if refreshErr != nil {
-		s.errorf(refreshErr.Error())
+	if err := s.addColumn(s.clientTable, "refresh_token_lifetime", int64(0), 0, "0"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.accessTable, "audience", "", 255, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.accessTable, "act", "", 1000, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "grant_types", "", 1000, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "auth_method", "", 255, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "registration_token", "", 255, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "id_token_alg", "", 255, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.authCodeTable, "nonce", "", 255, "''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.authCodeTable, "auth_time", int64(0), 0, "0"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.refreshTable, "expired_at", int64(0), 0, "0"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.refreshTable, "family_id", uuid.UUID{}, 0, fmt.Sprintf("'%s'", uuid.Nil)); err != nil {
+		return err
+	}
+	// refresh tokens issued before families start a family of their own
+	_, err := s.db.Exec(s.rebind(fmt.Sprintf("UPDATE %s SET family_id=id WHERE family_id=?", s.refreshTable)), uuid.Nil)
+	if err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "secret_created_at", int64(0), 0, "0"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "previous_secret", "", 255, "''"); err != nil {
+		return err
 	}
+	if err := s.addColumn(s.clientTable, "previous_created_at", int64(0), 0, "0"); err != nil {
+		return err
+	}
+	if err := s.addColumn(s.clientTable, "previous_expired_at", int64(0), 0, "0"); err != nil {
+		return err
+	}
+	return s.hashClientSecrets()
 }
 
-// errorf logs error
-func (s *Store) errorf(format string, args ...interface{}) {
-	if s.stdout != nil {
-		buf := fmt.Sprintf("[OAUTH2-MYSQL-ERROR]: "+format, args...)
-		_, _ = s.stdout.Write([]byte(buf))
+// hashClientSecrets replaces plain text secrets stored by older versions with their hashes and records
+// creation time of the client as issue time of secrets stored without one,
+// the update is guarded by the old value so replicas migrating at the same time do not hash a hash
+func (s *Store) hashClientSecrets() error {
+	var clients []model.Clients
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE secret<>? AND (secret NOT LIKE ? OR secret_created_at=?)", s.clientTable))
+	if _, err := s.db.Select(&clients, query, "", "$2%", 0); err != nil {
+		return err
+	}
+	updateQuery := s.rebind(fmt.Sprintf("UPDATE %s SET secret=?, secret_created_at=? WHERE id=? AND secret=?", s.clientTable))
+	for _, client := range clients {
+		hash, createdAt := client.Secret, client.SecretCreatedAt
+		if !util.IsSecretHash(hash) {
+			var err error
+			if hash, err = util.HashSecret(client.Secret); err != nil {
+				return err
+			}
+		}
+		if createdAt == 0 {
+			createdAt = client.CreatedAt.Unix()
+		}
+		if _, err := s.db.Exec(updateQuery, hash, createdAt, client.ID, client.Secret); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// CreateClient creates new client,
-// userId user's id who created the client
-func (s *Store) CreateClient(userId int64, name string) (model.Clients, error) {
-	client := model.Clients{}
-	if userId == 0 {
-		return client, errors.New(util.EmptyUserID)
-	}
-	client.ID = uuid.New()
-	client.Name = name
-	client.Secret = util.RandomKey(20)
-	client.UserId = userId
-	client.CreatedAt = time.Now()
-	client.UpdatedAt = time.Now()
-	err := s.db.Insert(&client)
-	if err != nil {
-		return client, err
+// addColumn adds column of Go type of kind to table unless it already exists,
+// size max size of string column, defaultValue sql literal set on existing rows
+func (s *Store) addColumn(table, column string, kind interface{}, size int, defaultValue string) error {
+	if _, err := s.db.Exec(fmt.Sprintf("SELECT %s FROM %s WHERE 1=0", column, table)); err == nil {
+		return nil
 	}
-	return client, nil
+	sqlType := s.db.Dialect.ToSqlType(reflect.TypeOf(kind), size, false)
+	_, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD %s %s NOT NULL DEFAULT %s", table, column, sqlType, defaultValue))
+	return err
 }
 
-// Create create and store the new token information
-func (s *Store) Create(info model.TokenInfo) (model.TokenResponse, error) {
-	_, publicPemNotExistserr := os.Stat(util.PublicPem)
-	_, privatePemNotExistserr := os.Stat(util.PublicPem)
+// Close close the store
+func (s *Store) Close() {
+	s.ticker.Stop()
+	s.releaseGCLease()
+	_ = s.db.Db.Close()
+}
 
-	// check if Public and Private key exists File is present
-	if os.IsNotExist(publicPemNotExistserr) || os.IsNotExist(privatePemNotExistserr) {
-		priv, pub := util.GenerateKeyPair(util.BitSize)
-		util.SavePEMKey(util.PrivatePem, priv)
-		util.SavePublicPEMKey(util.PublicPem, pub)
+func (s *Store) gc() {
+	for range s.ticker.C {
+		if !s.acquireGCLease() {
+			continue
+		}
+		if _, err := s.Clean(); err != nil {
+			s.errorf(err.Error())
+		}
 	}
+}
 
-	tokenResp := model.TokenResponse{}
-	if info.GetUserID() == 0 {
-		return tokenResp, errors.New(util.EmptyUserID)
-	}
+// EnableGCLeaderElection makes replicas sharing the database elect one of them to run garbage collection,
+// the leader holds a lease row for two GC intervals and renews it on every run,
+// another replica takes over once the lease of stopped leader expires or it is closed
+func (s *Store) EnableGCLeaderElection() {
+	atomic.StoreInt32(&s.leader, 0)
+	atomic.StoreInt32(&s.election, 1)
+}
 
-	//check if valid client
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id=? AND secret=? LIMIT 1", s.clientTable)
-	var client model.Clients
-	err := s.db.SelectOne(&client, query, info.GetClientID(), info.GetClientSecret())
+// IsLeader reports whether this instance runs garbage collection, it is always true without leader election,
+// with it the result of the last lease renewal
+func (s *Store) IsLeader() bool {
+	return atomic.LoadInt32(&s.election) == 0 || atomic.LoadInt32(&s.leader) == 1
+}
+
+// acquireGCLease takes or renews the garbage collection lease when leader election is enabled
+// and reports whether this instance should clean
+func (s *Store) acquireGCLease() bool {
+	if atomic.LoadInt32(&s.election) == 0 {
+		return true
+	}
+	leader, err := s.renewGCLease()
 	if err != nil {
-		return tokenResp, errors.New(util.InvalidClient)
+		s.errorf(err.Error())
 	}
-	if client.ID == uuid.Nil {
-		return tokenResp, errors.New(util.InvalidClient)
+	var value int32
+	if leader {
+		value = 1
 	}
+	atomic.StoreInt32(&s.leader, value)
+	return leader
+}
 
-	//create rsa pub
-	pubKeyFile, err := ioutil.ReadFile(util.PublicPem)
+// renewGCLease takes the lease when it is free or expired and extends it when this instance holds it,
+// the holder is read back as mysql reports no affected rows for update which changes nothing
+func (s *Store) renewGCLease() (bool, error) {
+	now := time.Now().Unix()
+	count, err := s.db.SelectInt(s.rebind(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE name=?", s.leaseTable)), util.GCLeaseName)
 	if err != nil {
-		return tokenResp, err
-	}
-	pubkey := util.BytesToPublicKey(pubKeyFile)
-	accessTokenPayload := model.AccessTokenPayload{}
-	accessId := uuid.New()
-	accessTokenPayload.UserId = info.GetUserID()
-	accessTokenPayload.ClientId = info.GetClientID()
-	accessTokenPayload.ExpiredAt = info.GetAccessCreateAt().Add(info.GetAccessExpiresIn()).Unix()
-	tokenResp.ExpiredAt = info.GetAccessCreateAt().Add(info.GetAccessExpiresIn()).Unix()
-	oauthAccess := &model.AccessTokens{
-		Model: model.Model{
-			ID:        accessId,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		},
-		AccessTokenPayload: accessTokenPayload,
-		Scope:              info.GetScope(),
-		Name:               "",
-		Revoked:            false,
-	}
-	accessByte := new(bytes.Buffer)
-	_ = json.NewEncoder(accessByte).Encode(accessTokenPayload)
-	accessToken, err := util.EncryptWithPublicKey(accessByte.Bytes(), pubkey)
+		return false, err
+	}
+	if count == 0 {
+		// concurrent insert of another replica fails on primary key, the update below decides
+		_ = s.db.Insert(&model.GCLeases{Name: util.GCLeaseName})
+	}
+	_, err = s.db.Exec(s.rebind(fmt.Sprintf("UPDATE %s SET holder=?, expired_at=? WHERE name=? AND (holder=? OR expired_at<?)", s.leaseTable)),
+		s.instanceId, now+s.gcInterval*2, util.GCLeaseName, s.instanceId, now)
 	if err != nil {
-		return tokenResp, err
+		return false, err
 	}
-	tokenResp.AccessToken = accessToken
-	tokenResp.ExpiredAt = accessTokenPayload.ExpiredAt
-
-	// set refresh
-	refreshTokenPayload := model.RefreshTokenPayload{}
-	refreshTokenPayload.AccessTokenId = accessId
-	refreshToken := &model.RefreshTokens{
-		Model: model.Model{
-			ID:        uuid.New(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		},
-		RefreshTokenPayload: refreshTokenPayload,
-		Revoked:             false,
+	var lease model.GCLeases
+	if err := s.db.SelectOne(&lease, s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE name=?", s.leaseTable)), util.GCLeaseName); err != nil {
+		return false, err
 	}
+	return lease.Holder == s.instanceId && lease.ExpiredAt >= now, nil
+}
 
-	refreshTokenByte := new(bytes.Buffer)
-	_ = json.NewEncoder(refreshTokenByte).Encode(refreshTokenPayload)
-
-	refToken, err := util.EncryptWithPublicKey(refreshTokenByte.Bytes(), pubkey)
-	tokenResp.RefreshToken = refToken
+// releaseGCLease gives up the lease held by this instance so another replica takes over on its next run
+func (s *Store) releaseGCLease() {
+	if !atomic.CompareAndSwapInt32(&s.leader, 1, 0) {
+		return
+	}
+	_, err := s.db.Exec(s.rebind(fmt.Sprintf("UPDATE %s SET expired_at=? WHERE name=? AND holder=?", s.leaseTable)),
+		0, util.GCLeaseName, s.instanceId)
 	if err != nil {
-		return tokenResp, err
+		s.errorf(err.Error())
 	}
+}
 
-	//revoke all old access tokens
-	//updateQuery := fmt.Sprintf("UPDATE %s SET `revoked`=? WHERE user_id = ?", s.accessTable)
-	//_, updateErr := s.db.Exec(updateQuery, 1, info.GetUserID())
-	//if updateErr != nil {
-	//	return tokenResp, updateErr
-	//}
-
-	accessErr := s.db.Insert(oauthAccess)
-	if accessErr != nil {
-		return tokenResp, accessErr
+// SetGCBatchSize sets maximum number of rows deleted by one statement of garbage collection (default util.GCBatchSize)
+func (s *Store) SetGCBatchSize(size int) {
+	if size > 0 {
+		s.gcBatchSize = size
 	}
+}
 
-	refErr := s.db.Insert(refreshToken)
-	if refErr != nil {
-		return tokenResp, refErr
+// Clean removes revoked and expired tokens, used and expired codes and expired assertion ids in batches,
+// so large tables are never locked at once, and reports number of removed rows,
+// cleaning continues with other tables when one fails and the first error is returned
+func (s *Store) Clean() (CleanReport, error) {
+	var report CleanReport
+	now := time.Now().Unix()
+	steps := []struct {
+		removed   *int64
+		table     string
+		condition string
+		args      []interface{}
+	}{
+		// expired access token is still needed to refresh it while its refresh token is alive
+		{&report.AccessTokens, s.accessTable, fmt.Sprintf("revoked=? OR (expired_at<? AND id NOT IN "+
+			"(SELECT access_token_id FROM %s WHERE revoked=? AND (expired_at=0 OR expired_at>=?)))", s.refreshTable),
+			[]interface{}{true, now, false, now}},
+		// refresh token whose access token is gone can never be used
+		{&report.RefreshTokens, s.refreshTable, fmt.Sprintf("revoked=? AND access_token_id NOT IN (SELECT id FROM %s)", s.accessTable),
+			[]interface{}{false}},
+		{&report.RefreshTokens, s.refreshTable, "expired_at<>0 AND expired_at<?", []interface{}{now}},
+		// used refresh tokens are kept while their family is alive to detect their reuse
+		{&report.RefreshTokens, s.refreshTable, fmt.Sprintf("revoked=? AND family_id NOT IN "+
+			"(SELECT family_id FROM (SELECT family_id FROM %s WHERE revoked=?) AS active)", s.refreshTable),
+			[]interface{}{true, false}},
+		{&report.AuthCodes, s.authCodeTable, "revoked=? OR expired_at<?", []interface{}{true, now}},
+		{&report.DeviceCodes, s.deviceCodeTable, "revoked=? OR expired_at<?", []interface{}{true, now}},
+		{&report.JTIs, s.jtiTable, "expired_at<?", []interface{}{now}},
+	}
+	var firstErr error
+	for _, step := range steps {
+		removed, err := s.deleteBatches(step.table, step.condition, step.args...)
+		*step.removed += removed
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return tokenResp, nil
+	return report, firstErr
 }
 
-// GetByAccess use the access token for token information data,
-// access Access token string
-func (s *Store) GetByAccess(access string) (*model.AccessTokens, error) {
-	accessToken, err := decryptAccessToken(access)
-	if err != nil {
-		return nil, err
-	}
-	currentTime := time.Now().Unix()
-	if accessToken.ExpiredAt < currentTime {
-		return nil, errors.New(util.AccessTokenExpired)
+// deleteBatches deletes rows of table matching condition, at most gcBatchSize rows per statement,
+// returns number of deleted rows
+func (s *Store) deleteBatches(table, condition string, args ...interface{}) (int64, error) {
+	// derived table lets mysql limit the subquery and select from the table being deleted
+	query := s.rebind(fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM (SELECT id FROM %s WHERE %s LIMIT %d) AS batch)",
+		table, table, condition, s.gcBatchSize))
+	var removed int64
+	for {
+		result, err := s.db.Exec(query, args...)
+		if err != nil {
+			return removed, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return removed, err
+		}
+		removed += affected
+		if affected < int64(s.gcBatchSize) {
+			return removed, nil
+		}
 	}
+}
 
-	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id=? AND expired_at=? LIMIT 1", s.accessTable)
-	var item model.AccessTokens
-	err = s.db.SelectOne(&item, query, accessToken.UserId, accessToken.ExpiredAt)
-	if err != nil {
-		return nil, errors.New(util.InvalidAccessToken)
+// errorf logs error
+func (s *Store) errorf(format string, args ...interface{}) {
+	if s.stdout != nil {
+		buf := fmt.Sprintf("[OAUTH2-SQL-ERROR]: "+format, args...)
+		_, _ = s.stdout.Write([]byte(buf))
 	}
-	if item.Revoked == true {
-		return nil, errors.New(util.AccessTokenRevoked)
+}
+
+// notFound returns error of given util message when lookup found no row, other errors are returned as they are
+func notFound(err error, message string) error {
+	if err == sql.ErrNoRows {
+		return errors.New(message)
 	}
-	return &item, nil
+	return err
 }
 
-// GetByRefresh use the refresh token for token information data,
-// refresh Refresh token string
-func (s *Store) GetByRefresh(refresh string) (*model.AccessTokens, error) {
-	accessToken, err := decryptRefreshToken(refresh)
+// execOne runs update query and reports whether it changed a row
+func (s *Store) execOne(query string, args ...interface{}) (bool, error) {
+	result, err := s.db.Exec(query, args...)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	query := fmt.Sprintf("SELECT * FROM %s WHERE access_token_id=? LIMIT 1", s.refreshTable)
-	var refreshToken model.RefreshTokens
-	err = s.db.SelectOne(&refreshToken, query, accessToken.AccessTokenId)
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return nil, errors.New(util.InvalidRefreshToken)
-	}
-	if refreshToken.Revoked == true {
-		return nil, errors.New(util.RefreshTokenRevoked)
+		return false, err
 	}
+	return affected == 1, nil
+}
+
+// InsertClient stores new client, its secret is already hashed
+func (s *Store) InsertClient(client model.Clients) error {
+	return s.db.Insert(&client)
+}
+
+// GetClient returns client of given id
+func (s *Store) GetClient(clientId uuid.UUID) (model.Clients, error) {
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE id=? LIMIT 1", s.clientTable))
+	var client model.Clients
+	err := s.db.SelectOne(&client, query, clientId)
+	return client, notFound(err, util.InvalidClient)
+}
 
-	//check if associated access token is revoked or not
-	checkAccessTokenQuery := fmt.Sprintf("SELECT * FROM %s WHERE id=? LIMIT 1", s.accessTable)
-	var accessTokenData model.AccessTokens
-	err = s.db.SelectOne(&accessTokenData, checkAccessTokenQuery, accessToken.AccessTokenId)
+// UpdateClient saves every column of client except its secrets and registration access token
+func (s *Store) UpdateClient(client model.Clients) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET name=?, redirect=?, public=?, scope=?, jwks=?, grant_types=?, auth_method=?, id_token_alg=?, "+
+		"access_token_lifetime=?, refresh_token_lifetime=?, updated_at=? WHERE id=?", s.clientTable))
+	updated, err := s.execOne(query, client.Name, client.Redirect, client.Public, client.Scope, client.JWKS, client.GrantTypes,
+		client.AuthMethod, client.IDTokenAlg, client.AccessTokenLifetime, client.RefreshTokenLifetime, client.UpdatedAt, client.ID)
 	if err != nil {
-		return nil, errors.New(util.InvalidRefreshToken)
+		return err
 	}
-	if accessTokenData.Revoked == true {
-		return nil, errors.New(util.InvalidRefreshToken)
+	if !updated {
+		return errors.New(util.InvalidClient)
 	}
+	return nil
+}
 
-	// revoke refresh token after one time use
-	updateQuery := fmt.Sprintf("UPDATE %s SET `revoked`=? WHERE access_token_id IN (?)", s.refreshTable)
-	_, err = s.db.Exec(updateQuery, 1, accessToken.AccessTokenId)
+// UpdateClientSecret saves secret of client and secret it replaced, the update is guarded by current secret
+// so concurrent rotations do not both succeed
+func (s *Store) UpdateClientSecret(client model.Clients, current string) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET secret=?, secret_created_at=?, previous_secret=?, previous_created_at=?, previous_expired_at=?, updated_at=? WHERE id=? AND secret=?", s.clientTable))
+	updated, err := s.execOne(query, client.Secret, client.SecretCreatedAt, client.PreviousSecret, client.PreviousCreatedAt,
+		client.PreviousExpiredAt, client.UpdatedAt, client.ID, current)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	// revoke associated access token after use
-	updateAccessTokenQuery := fmt.Sprintf("UPDATE %s SET `revoked`=? WHERE id=?", s.accessTable)
-	_, err = s.db.Exec(updateAccessTokenQuery, 1, accessToken.AccessTokenId)
-	if err != nil {
-		return nil, err
+	if !updated {
+		return errors.New(util.ConcurrentRotation)
 	}
-
-	return &accessTokenData, nil
+	return nil
 }
 
-// ClearByAccessToken clears all token related to user,
-// userId id of user whose access token needs to be cleared
-func (s *Store) ClearByAccessToken(userId int64) error {
-	checkAccessTokenQuery := fmt.Sprintf("SELECT * FROM %s WHERE user_id=? ", s.accessTable)
-	var accessTokenData []model.AccessTokens
-	_, err := s.db.Select(&accessTokenData, checkAccessTokenQuery, userId)
+// DeleteClient deletes client together with its access tokens, refresh tokens, authorization codes
+// and device codes in one transaction
+func (s *Store) DeleteClient(clientId uuid.UUID) error {
+	queries := []string{
+		fmt.Sprintf("DELETE FROM %s WHERE access_token_id IN (SELECT id FROM %s WHERE client_id=?)", s.refreshTable, s.accessTable),
+		fmt.Sprintf("DELETE FROM %s WHERE client_id=?", s.accessTable),
+		fmt.Sprintf("DELETE FROM %s WHERE client_id=?", s.authCodeTable),
+		fmt.Sprintf("DELETE FROM %s WHERE client_id=?", s.deviceCodeTable),
+		fmt.Sprintf("DELETE FROM %s WHERE id=?", s.clientTable),
+	}
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
-
-	//delete all related refreshtoken
-	for _, value := range accessTokenData {
-		query := fmt.Sprintf("DELETE FROM %s WHERE access_token_id=?", s.refreshTable)
-		_, err := s.db.Exec(query, value.ID)
-		if err != nil {
+	for _, query := range queries {
+		if _, err := tx.Exec(s.rebind(query), clientId); err != nil {
+			_ = tx.Rollback()
 			return err
 		}
 	}
+	return tx.Commit()
+}
 
-	//delete all access token related to user
-	query := fmt.Sprintf("DELETE FROM %s WHERE user_id=?", s.accessTable)
-	_, err = s.db.Exec(query, userId)
-	if err != nil && err == sql.ErrNoRows {
-		return nil
+// UseJTI records id of assertion used by client until expiredAt, the unique key rejects its replay
+func (s *Store) UseJTI(clientId uuid.UUID, jti string, expiredAt int64) error {
+	used := model.UsedJTIs{
+		Model: model.Model{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		ClientId:  clientId,
+		JTI:       jti,
+		ExpiredAt: expiredAt,
+	}
+	if err := s.db.Insert(&used); err != nil {
+		query := s.rebind(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE client_id=? AND jti=?", s.jtiTable))
+		if count, countErr := s.db.SelectInt(query, clientId, jti); countErr == nil && count > 0 {
+			return errors.New(util.AssertionReplayed)
+		}
+		return err
 	}
+	return nil
+}
+
+// InsertAccessToken stores new access token
+func (s *Store) InsertAccessToken(access model.AccessTokens) error {
+	return s.db.Insert(&access)
+}
+
+// GetAccessToken returns access token of given id
+func (s *Store) GetAccessToken(id uuid.UUID) (model.AccessTokens, error) {
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE id=? LIMIT 1", s.accessTable))
+	var item model.AccessTokens
+	err := s.db.SelectOne(&item, query, id)
+	return item, notFound(err, util.InvalidAccessToken)
+}
+
+// RevokeAccessToken marks access token of given id revoked
+func (s *Store) RevokeAccessToken(id uuid.UUID) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=?, updated_at=? WHERE id=?", s.accessTable))
+	_, err := s.db.Exec(query, true, time.Now(), id)
 	return err
 }
 
-// RevokeRefreshToken revokes token from RefreshToken,
-func (s *Store) RevokeRefreshToken(accessTokenId string) error {
-	query := fmt.Sprintf("UPDATE %s SET `revoked`=? WHERE access_token_id IN (?)", s.refreshTable)
-	_, err := s.db.Exec(query, 1, accessTokenId)
+// RevokeByAccessTokens marks every access token of given user revoked
+func (s *Store) RevokeByAccessTokens(userId int64) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=? WHERE user_id=?", s.accessTable))
+	_, err := s.db.Exec(query, true, userId)
 	if err != nil && err == sql.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-// RevokeByAccessTokens revokes token from accessToken
-func (s *Store) RevokeByAccessTokens(userId int64) error {
-	query := fmt.Sprintf("UPDATE %s SET `revoked`=? WHERE user_id IN (?)", s.accessTable)
-	_, err := s.db.Exec(query, 1, userId)
+// ClearByAccessToken deletes every access token of given user together with its refresh token
+func (s *Store) ClearByAccessToken(userId int64) error {
+	//delete all related refreshtoken
+	query := s.rebind(fmt.Sprintf("DELETE FROM %s WHERE access_token_id IN (SELECT id FROM %s WHERE user_id=?)", s.refreshTable, s.accessTable))
+	_, err := s.db.Exec(query, userId)
+	if err != nil {
+		return err
+	}
+
+	//delete all access token related to user
+	query = s.rebind(fmt.Sprintf("DELETE FROM %s WHERE user_id=?", s.accessTable))
+	_, err = s.db.Exec(query, userId)
 	if err != nil && err == sql.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-// decryptAccessToken decrypts given access token
-func decryptAccessToken(token string) (*model.AccessTokenPayload, error) {
-	var tm model.AccessTokenPayload
-	pKey, err := ioutil.ReadFile(util.PrivatePem)
-	if err != nil {
-		return &tm, err
+// InsertRefreshToken stores new refresh token
+func (s *Store) InsertRefreshToken(refresh model.RefreshTokens) error {
+	return s.db.Insert(&refresh)
+}
+
+// GetRefreshToken returns refresh token issued alongside given access token
+func (s *Store) GetRefreshToken(accessTokenId uuid.UUID) (model.RefreshTokens, error) {
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE access_token_id=? LIMIT 1", s.refreshTable))
+	var refreshToken model.RefreshTokens
+	err := s.db.SelectOne(&refreshToken, query, accessTokenId)
+	return refreshToken, notFound(err, util.InvalidRefreshToken)
+}
+
+// UseRefreshToken marks refresh token of given id revoked, only the request which flips revoked gets true
+func (s *Store) UseRefreshToken(id uuid.UUID) (bool, error) {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=?, updated_at=? WHERE id=? AND revoked=?", s.refreshTable))
+	return s.execOne(query, true, time.Now(), id, false)
+}
+
+// RevokeRefreshFamily marks every refresh token of given family and their access tokens revoked
+// and returns those access tokens
+func (s *Store) RevokeRefreshFamily(familyId uuid.UUID) ([]model.AccessTokens, error) {
+	familyAccess := fmt.Sprintf("SELECT access_token_id FROM %s WHERE family_id=?", s.refreshTable)
+	var accessTokens []model.AccessTokens
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE id IN (%s)", s.accessTable, familyAccess))
+	if _, err := s.db.Select(&accessTokens, query, familyId); err != nil {
+		return nil, err
 	}
-	privKey := util.BytesToPrivateKey(pKey)
-	dec, err := util.DecryptWithPrivateKey(token, privKey)
-	if err != nil {
-		return &tm, err
+
+	accessQuery := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=?, updated_at=? WHERE id IN (%s)", s.accessTable, familyAccess))
+	if _, err := s.db.Exec(accessQuery, true, time.Now(), familyId); err != nil {
+		return nil, err
 	}
-	_ = jsoniter.Unmarshal([]byte(dec), &tm)
-	if tm.UserId == 0 {
-		return &tm, errors.New(util.InvalidAccessToken)
+	refreshQuery := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=?, updated_at=? WHERE family_id=?", s.refreshTable))
+	if _, err := s.db.Exec(refreshQuery, true, time.Now(), familyId); err != nil {
+		return nil, err
 	}
-	return &tm, nil
+	return accessTokens, nil
 }
 
-// decryptRefreshToken decrypts given refresh token
-func decryptRefreshToken(token string) (*model.RefreshTokenPayload, error) {
-	var tm model.RefreshTokenPayload
-	pKey, err := ioutil.ReadFile(util.PrivatePem)
-	if err != nil {
-		return &tm, err
+// DeleteRefreshToken deletes refresh token issued alongside given access token
+func (s *Store) DeleteRefreshToken(accessTokenId uuid.UUID) error {
+	query := s.rebind(fmt.Sprintf("DELETE FROM %s WHERE access_token_id=?", s.refreshTable))
+	_, err := s.db.Exec(query, accessTokenId)
+	if err != nil && err == sql.ErrNoRows {
+		return nil
 	}
-	privKey := util.BytesToPrivateKey(pKey)
-	decipher, err := util.DecryptWithPrivateKey(token, privKey)
+	return err
+}
+
+// InsertAuthCode stores new authorization code
+func (s *Store) InsertAuthCode(code model.AuthCodes) error {
+	return s.db.Insert(&code)
+}
+
+// GetAuthCode returns authorization code of given id
+func (s *Store) GetAuthCode(id uuid.UUID) (model.AuthCodes, error) {
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE id=? LIMIT 1", s.authCodeTable))
+	var authCode model.AuthCodes
+	err := s.db.SelectOne(&authCode, query, id)
+	return authCode, notFound(err, util.InvalidAuthCode)
+}
+
+// UseAuthCode marks authorization code of given id revoked, only the request which flips revoked gets true
+func (s *Store) UseAuthCode(id uuid.UUID) (bool, error) {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=?, updated_at=? WHERE id=? AND revoked=?", s.authCodeTable))
+	return s.execOne(query, true, time.Now(), id, false)
+}
+
+// InsertDeviceCode stores new device authorization, the unique key rejects user code already in use
+func (s *Store) InsertDeviceCode(code model.DeviceCodes) error {
+	return s.db.Insert(&code)
+}
+
+// GetDeviceCode returns device authorization of given id
+func (s *Store) GetDeviceCode(id uuid.UUID) (model.DeviceCodes, error) {
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE id=? LIMIT 1", s.deviceCodeTable))
+	var code model.DeviceCodes
+	err := s.db.SelectOne(&code, query, id)
+	return code, notFound(err, util.InvalidDeviceCode)
+}
+
+// GetDeviceCodeByUserCode returns device authorization of given normalized user code
+func (s *Store) GetDeviceCodeByUserCode(userCode string) (model.DeviceCodes, error) {
+	query := s.rebind(fmt.Sprintf("SELECT * FROM %s WHERE user_code=? LIMIT 1", s.deviceCodeTable))
+	var code model.DeviceCodes
+	err := s.db.SelectOne(&code, query, userCode)
+	return code, notFound(err, util.InvalidUserCode)
+}
+
+// ApproveDeviceCode approves pending device authorization of given normalized user code,
+// userId id of logged in user tokens are issued for
+func (s *Store) ApproveDeviceCode(userCode string, userId int64) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET approved=?, user_id=?, updated_at=? "+
+		"WHERE user_code=? AND approved=? AND denied=? AND revoked=? AND expired_at>=?", s.deviceCodeTable))
+	return s.decideDeviceCode(query, true, userId, time.Now(), userCode, false, false, false, time.Now().Unix())
+}
+
+// DenyDeviceCode denies pending device authorization of given normalized user code
+func (s *Store) DenyDeviceCode(userCode string) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET denied=?, updated_at=? "+
+		"WHERE user_code=? AND approved=? AND denied=? AND revoked=? AND expired_at>=?", s.deviceCodeTable))
+	return s.decideDeviceCode(query, true, time.Now(), userCode, false, false, false, time.Now().Unix())
+}
+
+// decideDeviceCode runs approve or deny query, only pending device authorization may be decided
+func (s *Store) decideDeviceCode(query string, args ...interface{}) error {
+	decided, err := s.execOne(query, args...)
 	if err != nil {
-		return &tm, err
+		return err
 	}
-	_ = jsoniter.Unmarshal([]byte(decipher), &tm)
-	if tm.AccessTokenId == uuid.Nil {
-		return &tm, errors.New(util.InvalidRefreshToken)
+	if !decided {
+		return errors.New(util.InvalidUserCode)
 	}
-	return &tm, nil
+	return nil
+}
+
+// UpdateDeviceCodePoll saves last poll time and poll interval of device authorization
+func (s *Store) UpdateDeviceCodePoll(code model.DeviceCodes) error {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET last_polled_at=?, poll_interval=?, updated_at=? WHERE id=?", s.deviceCodeTable))
+	_, err := s.db.Exec(query, code.LastPolledAt, code.PollInterval, code.UpdatedAt, code.ID)
+	return err
+}
+
+// UseDeviceCode marks device authorization of given id revoked, only the request which flips revoked gets true
+func (s *Store) UseDeviceCode(id uuid.UUID) (bool, error) {
+	query := s.rebind(fmt.Sprintf("UPDATE %s SET revoked=?, updated_at=? WHERE id=? AND revoked=?", s.deviceCodeTable))
+	return s.execOne(query, true, time.Now(), id, false)
 }
@@ -5,100 +5,178 @@ import (
 	"github.com/gobeam/golang-oauth/model"
 	"github.com/gobeam/golang-oauth/util"
 	"github.com/google/uuid"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
-var dbStore *Store
-var accessTokenString string
-var refreshTokenString string
-var accessId uuid.UUID
 var userID int64 = 1
-var clientDetail *model.Clients
 
-func init() {
-	store := NewDefaultStore(
-		NewConfig(util.DbConfig),
-	)
-	dbStore = store
-}
+// testStores returns every backend the suite runs against,
+// in-memory and sqlite (file inside dir) always run, mysql and postgres only when OAUTH_MYSQL_DSN or OAUTH_POSTGRES_DSN is set
+func testStores(dir string) map[string]*Manager {
+	stores := map[string]*Manager{
+		"memory": NewManager(NewDefaultMemoryStore()),
+		"sqlite": NewManager(NewDefaultStore(NewSQLiteConfig(filepath.Join(dir, "oauth.db")))),
+	}
+	jwtStore := NewManager(NewDefaultStore(NewSQLiteConfig(filepath.Join(dir, "oauth-jwt.db"))))
+	keys := NewKeySet()
+	_, _ = keys.Rotate(util.RS256)
+	_, _ = keys.Rotate(util.ES256)
+	jwtStore.SetKeyProvider(keys)
+	_ = jwtStore.SetTokenFormat(util.TokenFormatJWT, util.ES256)
+	stores["sqlite-jwt"] = jwtStore
 
-func TestCreateClient(t *testing.T) {
-	client, err := dbStore.CreateClient(1, "test app")
-	if err != nil {
-		t.Error(err.Error())
+	if dsn := os.Getenv("OAUTH_MYSQL_DSN"); dsn != "" {
+		stores["mysql"] = NewManager(NewDefaultStore(NewConfig(dsn)))
 	}
-	clientDetail = &client
-	if client.ID == uuid.Nil {
-		t.Errorf("Client uuid invalid client not expected to be %s", uuid.Nil)
+	if dsn := os.Getenv("OAUTH_POSTGRES_DSN"); dsn != "" {
+		stores["postgres"] = NewManager(NewDefaultStore(NewPostgresConfig(dsn)))
 	}
+	return stores
 }
 
-func TestCreate(t *testing.T) {
-	accessToken := &model.Token{
-		ClientID:        clientDetail.ID,
-		ClientSecret:    clientDetail.Secret,
-		UserID:          userID,
-		Scope:           "*",
-		AccessCreateAt:  time.Now(),
-		AccessExpiresIn: time.Second * 15,
-		RefreshCreateAt: time.Now(),
-	}
-	resp, err := dbStore.Create(accessToken)
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golang-oauth")
 	if err != nil {
-		t.Error(err.Error())
-	}
-	if resp.RefreshToken == "" {
-		t.Error("refresh token cannot be nil")
+		t.Fatal(err.Error())
 	}
-	refreshTokenString = resp.RefreshToken
-	if resp.AccessToken == "" {
-		t.Error("access token cannot be nil")
+	defer os.RemoveAll(dir)
+
+	for name, store := range testStores(dir) {
+		t.Run(name, func(t *testing.T) {
+			defer store.Close()
+			testStore(t, store)
+			t.Run("AuthCode", func(t *testing.T) {
+				testAuthCode(t, store)
+			})
+			t.Run("ClientCredentials", func(t *testing.T) {
+				testClientCredentials(t, store)
+			})
+			t.Run("DeviceCode", func(t *testing.T) {
+				testDeviceCode(t, store)
+			})
+			t.Run("TokenExchange", func(t *testing.T) {
+				testTokenExchange(t, store)
+			})
+			t.Run("Assertion", func(t *testing.T) {
+				testAssertion(t, store)
+			})
+			t.Run("RefreshRotation", func(t *testing.T) {
+				testRefreshRotation(t, store)
+			})
+			t.Run("RefreshExpiry", func(t *testing.T) {
+				testRefreshExpiry(t, store)
+			})
+			t.Run("Introspection", func(t *testing.T) {
+				testIntrospection(t, store)
+			})
+			t.Run("Revocation", func(t *testing.T) {
+				testRevocation(t, store)
+			})
+			t.Run("OIDC", func(t *testing.T) {
+				testOIDC(t, store)
+			})
+			t.Run("Registration", func(t *testing.T) {
+				testRegistration(t, store)
+			})
+			t.Run("SecretRotation", func(t *testing.T) {
+				testSecretRotation(t, store)
+			})
+		})
 	}
-	accessTokenString = resp.AccessToken
 }
 
-func TestGetByAccess(t *testing.T) {
+// testStore runs the token lifecycle against given store
+func testStore(t *testing.T, dbStore *Manager) {
+	var accessTokenString string
+	var refreshTokenString string
+	var accessId uuid.UUID
+	var clientDetail *model.Clients
 
-	resp, err := dbStore.GetByAccess(accessTokenString)
-	if err != nil {
-		t.Error(err.Error())
-	}
-	if resp.ID == uuid.Nil {
-		t.Errorf("token info uuid is not expected to be %s", uuid.Nil)
-	}
-}
+	t.Run("CreateClient", func(t *testing.T) {
+		client, err := dbStore.CreateClient(1, "test app")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		clientDetail = &client
+		if client.ID == uuid.Nil {
+			t.Errorf("Client uuid invalid client not expected to be %s", uuid.Nil)
+		}
+	})
 
-func TestGetByRefresh(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		accessToken := &model.Token{
+			ClientID:        clientDetail.ID,
+			ClientSecret:    clientDetail.Secret,
+			UserID:          userID,
+			Scope:           "*",
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Second * 15,
+			RefreshCreateAt: time.Now(),
+		}
+		resp, err := dbStore.Create(accessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.RefreshToken == "" {
+			t.Error("refresh token cannot be nil")
+		}
+		refreshTokenString = resp.RefreshToken
+		if resp.AccessToken == "" {
+			t.Error("access token cannot be nil")
+		}
+		accessTokenString = resp.AccessToken
+	})
 
-	resp, err := dbStore.GetByRefresh(refreshTokenString)
-	if err != nil {
-		t.Error(err.Error())
-	}
-	if resp.ID == uuid.Nil {
-		t.Errorf("token info uuid is not expected to be %s", uuid.Nil)
-	}
-	accessId = resp.ID
-}
+	t.Run("GetByAccess", func(t *testing.T) {
+		resp, err := dbStore.GetByAccess(accessTokenString)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.ID == uuid.Nil {
+			t.Errorf("token info uuid is not expected to be %s", uuid.Nil)
+		}
+	})
 
-func TestRevokeByAccessTokens(t *testing.T) {
-	err := dbStore.RevokeByAccessTokens(userID)
-	if err != nil {
-		t.Error(err.Error())
-	}
-}
+	t.Run("GetByRefresh", func(t *testing.T) {
+		resp, err := dbStore.GetByRefresh(refreshTokenString)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.ID == uuid.Nil {
+			t.Errorf("token info uuid is not expected to be %s", uuid.Nil)
+		}
+		accessId = resp.ID
+	})
 
-func TestRevokeRefreshToken(t *testing.T) {
-	err := dbStore.RevokeRefreshToken(accessId.String())
-	if err != nil {
-		t.Error(err.Error())
-	}
-}
+	t.Run("RevokeByAccessTokens", func(t *testing.T) {
+		err := dbStore.RevokeByAccessTokens(userID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		// user id 0 would match client credentials tokens
+		if err := dbStore.RevokeByAccessTokens(0); err == nil || err.Error() != util.EmptyUserID {
+			t.Errorf("expected %s, got %v", util.EmptyUserID, err)
+		}
+	})
 
-func TestClearByAccessToken(t *testing.T) {
-	err := dbStore.ClearByAccessToken(userID)
-	if err != nil {
-		t.Error(err.Error())
-	}
-	defer dbStore.Close()
+	t.Run("RevokeRefreshToken", func(t *testing.T) {
+		err := dbStore.RevokeRefreshToken(accessId.String())
+		if err != nil {
+			t.Error(err.Error())
+		}
+	})
+
+	t.Run("ClearByAccessToken", func(t *testing.T) {
+		err := dbStore.ClearByAccessToken(userID)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if err := dbStore.ClearByAccessToken(0); err == nil || err.Error() != util.EmptyUserID {
+			t.Errorf("expected %s, got %v", util.EmptyUserID, err)
+		}
+	})
 }
@@ -0,0 +1,177 @@
+package golang_oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClaimsProvider supplies claims about users (OpenID Connect Core 5.1) for ID tokens and userinfo endpoint,
+// scopes are scopes granted to the client, standard claims of scopes which were not granted are dropped
+type ClaimsProvider interface {
+	UserClaims(userId int64, scopes []string) (map[string]interface{}, error)
+}
+
+// ClaimsProviderFunc adapts plain function to ClaimsProvider
+type ClaimsProviderFunc func(userId int64, scopes []string) (map[string]interface{}, error)
+
+// UserClaims calls f(userId, scopes)
+func (f ClaimsProviderFunc) UserClaims(userId int64, scopes []string) (map[string]interface{}, error) {
+	return f(userId, scopes)
+}
+
+// scopeClaims are standard claims released by scope values (OpenID Connect Core 5.4)
+var scopeClaims = map[string][]string{
+	"profile": {"name", "family_name", "given_name", "middle_name", "nickname", "preferred_username",
+		"profile", "picture", "website", "gender", "birthdate", "zoneinfo", "locale", "updated_at"},
+	"email":   {"email", "email_verified"},
+	"address": {"address"},
+	"phone":   {"phone_number", "phone_number_verified"},
+}
+
+// idTokenClaims are claims set by the server which providers may not override
+var idTokenClaims = []string{"iss", "sub", "aud", "exp", "iat", "nonce", "auth_time", "at_hash", "azp"}
+
+// SetIssuer sets issuer identifier of the server put in iss claim, an https url without query or fragment,
+// ID tokens are issued for openid scope only once issuer is set
+func (c *tokenCodec) SetIssuer(issuer string) {
+	c.issuer = strings.TrimSuffix(issuer, "/")
+}
+
+// Issuer returns issuer identifier of the server, empty when OpenID Connect is not enabled
+func (c *tokenCodec) Issuer() string {
+	return c.issuer
+}
+
+// SetClaimsProvider sets provider of user claims put in ID tokens and returned by userinfo endpoint,
+// without provider only sub claim is released
+func (c *tokenCodec) SetClaimsProvider(provider ClaimsProvider) {
+	c.claims = provider
+}
+
+// idTokenAlgorithms returns algorithms ID tokens may be signed with, util.RS256 which every client must
+// support (OpenID Connect Core 15.1) and util.ES256 when key provider has an EC key
+func (c *tokenCodec) idTokenAlgorithms() []string {
+	algorithms := []string{util.RS256}
+	if keys, err := c.keyProvider(); err == nil {
+		if _, err := keys.Key(util.ES256); err == nil {
+			algorithms = append(algorithms, util.ES256)
+		}
+	}
+	return algorithms
+}
+
+// idTokenAlgorithm returns algorithm ID tokens of client are signed with, id_token_signed_response_alg
+// the client registered or util.RS256
+func idTokenAlgorithm(client model.Clients) string {
+	if client.IDTokenAlg != "" {
+		return client.IDTokenAlg
+	}
+	return util.RS256
+}
+
+// hasScope reports whether space separated scope contains given value
+func hasScope(scope, value string) bool {
+	for _, item := range strings.Fields(scope) {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// userClaims returns claims of user released for granted scope, sub is always set
+func (c *tokenCodec) userClaims(userId int64, scope string) (map[string]interface{}, error) {
+	claims := make(map[string]interface{})
+	if c.claims != nil {
+		scopes := strings.Fields(scope)
+		provided, err := c.claims.UserClaims(userId, scopes)
+		if err != nil {
+			return nil, err
+		}
+		released := make(map[string]bool)
+		for _, item := range scopes {
+			for _, name := range scopeClaims[item] {
+				released[name] = true
+			}
+		}
+		standard := make(map[string]bool)
+		for _, names := range scopeClaims {
+			for _, name := range names {
+				standard[name] = true
+			}
+		}
+		for name, value := range provided {
+			if !standard[name] || released[name] {
+				claims[name] = value
+			}
+		}
+		for _, name := range idTokenClaims {
+			delete(claims, name)
+		}
+	}
+	claims["sub"] = strconv.FormatInt(userId, 10)
+	return claims, nil
+}
+
+// atHash returns at_hash claim of access token, left half of its SHA-256 hash (OpenID Connect Core 3.1.3.6),
+// SHA-256 matches both RS256 and ES256
+func atHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// withIDToken adds ID token (OpenID Connect Core 2) signed with algorithm of client to token response
+// of authorization code when the code was issued for openid scope and issuer is set
+func (c *tokenCodec) withIDToken(tokenResp model.TokenResponse, code model.AuthCodes, client model.Clients) (model.TokenResponse, error) {
+	if c.issuer == "" || !hasScope(code.Scope, util.ScopeOpenID) {
+		return tokenResp, nil
+	}
+	claims, err := c.userClaims(code.UserId, code.Scope)
+	if err != nil {
+		return tokenResp, err
+	}
+	claims["iss"] = c.issuer
+	claims["aud"] = code.ClientId.String()
+	claims["exp"] = tokenResp.ExpiredAt
+	claims["iat"] = time.Now().Unix()
+	claims["auth_time"] = code.AuthTime
+	claims["at_hash"] = atHash(tokenResp.AccessToken)
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+	key, err := c.key(idTokenAlgorithm(client))
+	if err != nil {
+		return tokenResp, err
+	}
+	idToken, err := util.SignJWT(claims, key.Algorithm, key.ID, key.Signer)
+	if err != nil {
+		return tokenResp, err
+	}
+	tokenResp.IDToken = idToken
+	return tokenResp, nil
+}
+
+// userInfo returns claims of the user access token was issued for (OpenID Connect Core 5.3),
+// token must be issued to user with openid scope
+func (c *tokenCodec) userInfo(access *model.AccessTokens) (map[string]interface{}, error) {
+	if access.UserId == 0 || !hasScope(access.Scope, util.ScopeOpenID) {
+		return nil, errors.New(util.MissingOpenIDScope)
+	}
+	return c.userClaims(access.UserId, access.Scope)
+}
+
+// UserInfo returns claims of the user given access token was issued for,
+// access Access token string with openid scope
+func (m *Manager) UserInfo(access string) (map[string]interface{}, error) {
+	oauthAccess, err := m.GetByAccess(access)
+	if err != nil {
+		return nil, err
+	}
+	return m.userInfo(oauthAccess)
+}
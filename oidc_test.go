@@ -0,0 +1,161 @@
+package golang_oauth
+
+import (
+	"crypto"
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// testOIDC runs ID token issuance and userinfo against given store
+func testOIDC(t *testing.T, store *Manager) {
+	store.SetIssuer("https://auth.example.com/")
+	store.SetClaimsProvider(ClaimsProviderFunc(func(userId int64, scopes []string) (map[string]interface{}, error) {
+		if userId != userID {
+			return nil, errors.New("unknown user")
+		}
+		return map[string]interface{}{
+			"name":         "Jane Doe",
+			"email":        "jane@example.com",
+			"phone_number": "+1 555 0100",
+			"department":   "engineering",
+			"iss":          "https://evil.example.com",
+		}, nil
+	}))
+	defer store.SetIssuer("")
+	defer store.SetClaimsProvider(nil)
+
+	client, err := store.CreateAuthCodeClient(userID, "rp", []string{testRedirectURI}, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	exchange := func(scope, nonce string) model.TokenResponse {
+		return exchangeCode(t, store, client.ID, client.Secret, scope, nonce, authTime)
+	}
+
+	resp := exchange("openid email", "n-0S6_WzA2Mj")
+	if resp.IDToken == "" {
+		t.Fatal("expected id token for openid scope")
+	}
+	var claims map[string]interface{}
+	// ID tokens are signed with RS256 whatever algorithm JWT access tokens use
+	if alg := verifyIDToken(t, store, resp.IDToken, &claims); alg != util.RS256 {
+		t.Errorf("expected id token signed with %s, got %s", util.RS256, alg)
+	}
+	expected := map[string]interface{}{
+		"iss":        "https://auth.example.com",
+		"sub":        "1",
+		"aud":        client.ID.String(),
+		"nonce":      "n-0S6_WzA2Mj",
+		"auth_time":  float64(authTime.Unix()),
+		"at_hash":    atHash(resp.AccessToken),
+		"email":      "jane@example.com",
+		"department": "engineering",
+	}
+	for name, value := range expected {
+		if claims[name] != value {
+			t.Errorf("expected %s %v, got %v", name, value, claims[name])
+		}
+	}
+	for _, name := range []string{"name", "phone_number"} {
+		if _, ok := claims[name]; ok {
+			t.Errorf("claim %s of scope which was not granted must not be released", name)
+		}
+	}
+	if claims["exp"] != float64(resp.ExpiredAt) {
+		t.Errorf("expected exp %d, got %v", resp.ExpiredAt, claims["exp"])
+	}
+
+	info, err := store.UserInfo(resp.AccessToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if info["sub"] != "1" || info["email"] != "jane@example.com" || info["iss"] != nil || info["name"] != nil {
+		t.Errorf("unexpected userinfo %v", info)
+	}
+
+	// no id token or userinfo without openid scope
+	plain := exchange("email", "")
+	if plain.IDToken != "" {
+		t.Error("id token must not be issued without openid scope")
+	}
+	if _, err := store.UserInfo(plain.AccessToken); err == nil || err.Error() != util.MissingOpenIDScope {
+		t.Errorf("expected %s, got %v", util.MissingOpenIDScope, err)
+	}
+	if _, err := store.UserInfo("invalid"); err == nil || err.Error() != util.InvalidAccessToken {
+		t.Errorf("expected %s, got %v", util.InvalidAccessToken, err)
+	}
+
+	// client may register ES256 when the store has an EC key
+	keys, err := store.keyProvider()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, noEC := keys.Key(util.ES256)
+	registration, err := store.RegisterClient(userID, model.ClientMetadata{
+		RedirectURIs:             []string{testRedirectURI},
+		IDTokenSignedResponseAlg: util.ES256,
+	})
+	if noEC != nil {
+		if err == nil || err.Error() != util.InvalidClientMetadata {
+			t.Errorf("expected %s without EC key, got %v", util.InvalidClientMetadata, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp = exchangeCode(t, store, uuid.MustParse(registration.ClientId), registration.ClientSecret, util.ScopeOpenID, "", authTime)
+	if alg := verifyIDToken(t, store, resp.IDToken, &claims); alg != util.ES256 {
+		t.Errorf("expected id token signed with %s, got %s", util.ES256, alg)
+	}
+}
+
+// exchangeCode issues authorization code for client and exchanges it for tokens
+func exchangeCode(t *testing.T, store *Manager, clientId uuid.UUID, secret, scope, nonce string, authTime time.Time) model.TokenResponse {
+	code, err := store.CreateAuthCode(model.AuthCodeRequest{
+		ClientID:    clientId,
+		UserID:      userID,
+		RedirectURI: testRedirectURI,
+		Scope:       scope,
+		Nonce:       nonce,
+		AuthTime:    authTime,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := store.ExchangeAuthCode(code, "", &model.Token{
+		ClientID:        clientId,
+		ClientSecret:    secret,
+		RedirectURI:     testRedirectURI,
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return resp
+}
+
+// verifyIDToken verifies signature of ID token with keys of the store, decodes its claims and returns its algorithm
+func verifyIDToken(t *testing.T, store *Manager, idToken string, claims interface{}) string {
+	header, err := util.ParseJWT(idToken, claims, func(header util.JWTHeader) (crypto.PublicKey, error) {
+		keys, err := store.keyProvider()
+		if err != nil {
+			return nil, err
+		}
+		key, err := keys.KeyByID(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		return key.Signer.Public(), nil
+	})
+	if err != nil {
+		t.Fatalf("invalid id token signature: %v", err)
+	}
+	return header.Alg
+}
@@ -0,0 +1,70 @@
+package golang_oauth
+
+import (
+	"database/sql"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq" //postgres driver for NewStore
+	"gopkg.in/gorp.v2"
+	"reflect"
+)
+
+// PostgresDialect gorp postgres dialect which stores uuid.UUID fields in native uuid columns
+type PostgresDialect struct {
+	gorp.PostgresDialect
+}
+
+// postgresUUID scan holder for uuid columns, it deliberately does not implement driver.Valuer
+// so gorp passes its own type to PostgresDialect.ToSqlType while creating tables
+type postgresUUID uuid.UUID
+
+// Scan implements sql.Scanner
+func (u *postgresUUID) Scan(src interface{}) error {
+	return (*uuid.UUID)(u).Scan(src)
+}
+
+// postgresTypeConverter scans uuid.UUID fields through postgresUUID
+type postgresTypeConverter struct{}
+
+// ToDb implements gorp.TypeConverter, uuid.UUID already implements driver.Valuer
+func (postgresTypeConverter) ToDb(val interface{}) (interface{}, error) {
+	return val, nil
+}
+
+// FromDb implements gorp.TypeConverter
+func (postgresTypeConverter) FromDb(target interface{}) (gorp.CustomScanner, bool) {
+	if _, ok := target.(*uuid.UUID); !ok {
+		return gorp.CustomScanner{}, false
+	}
+	binder := func(holder, target interface{}) error {
+		*target.(*uuid.UUID) = uuid.UUID(*holder.(*postgresUUID))
+		return nil
+	}
+	return gorp.CustomScanner{Holder: new(postgresUUID), Target: target, Binder: binder}, true
+}
+
+// ToSqlType maps uuid.UUID to uuid and falls back to gorp.PostgresDialect for every other type
+func (d PostgresDialect) ToSqlType(val reflect.Type, maxsize int, isAutoIncr bool) string {
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val == reflect.TypeOf(postgresUUID{}) || val == reflect.TypeOf(uuid.UUID{}) {
+		return "uuid"
+	}
+	return d.PostgresDialect.ToSqlType(val, maxsize, isAutoIncr)
+}
+
+// NewPostgresConfig create postgres configuration instance,
+// dsn postgres connection string
+func NewPostgresConfig(dsn string) *Config {
+	config := NewConfig(dsn)
+	config.Driver = util.DriverPostgres
+	return config
+}
+
+// NewPostgresStoreWithDB create postgres store instance,
+// db sql.DB opened with postgres driver,
+// GC time interval (in seconds, default 600)
+func NewPostgresStoreWithDB(db *sql.DB, gcInterval int) *Store {
+	return NewStoreWithDialect(db, PostgresDialect{}, gcInterval)
+}
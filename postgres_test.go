@@ -0,0 +1,54 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/google/uuid"
+	"gopkg.in/gorp.v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostgresDialectUUID(t *testing.T) {
+	dialect := PostgresDialect{}
+	if typ := dialect.ToSqlType(reflect.TypeOf(uuid.UUID{}), 0, false); typ != "uuid" {
+		t.Errorf("expected uuid column type, got %s", typ)
+	}
+	if typ := dialect.ToSqlType(reflect.TypeOf(true), 0, false); typ != "boolean" {
+		t.Errorf("expected boolean column type, got %s", typ)
+	}
+
+	dbMap := &gorp.DbMap{Dialect: dialect, TypeConverter: postgresTypeConverter{}}
+	sql := dbMap.AddTableWithName(model.AccessTokens{}, "oauth_access_tokens").SqlForCreate(true)
+	if !strings.Contains(sql, `"id" uuid`) || !strings.Contains(sql, `"client_id" uuid`) {
+		t.Errorf("expected uuid columns in %s", sql)
+	}
+
+	id := uuid.New()
+	var target uuid.UUID
+	scanner, ok := postgresTypeConverter{}.FromDb(&target)
+	if !ok {
+		t.Fatal("expected uuid target to be converted")
+	}
+	if err := scanner.Holder.(*postgresUUID).Scan([]byte(id.String())); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := scanner.Bind(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if target != id {
+		t.Errorf("expected %s, got %s", id, target)
+	}
+}
+
+func TestStoreRebind(t *testing.T) {
+	query := "SELECT * FROM oauth_clients WHERE id=? AND secret=? LIMIT 1"
+	postgres := &Store{db: &gorp.DbMap{Dialect: PostgresDialect{}}}
+	if got := postgres.rebind(query); got != "SELECT * FROM oauth_clients WHERE id=$1 AND secret=$2 LIMIT 1" {
+		t.Errorf("unexpected postgres query %s", got)
+	}
+	mysql := &Store{db: &gorp.DbMap{Dialect: dialectFor("mysql")}}
+	if got := mysql.rebind(query); got != query {
+		t.Errorf("unexpected mysql query %s", got)
+	}
+}
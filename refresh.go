@@ -0,0 +1,167 @@
+package golang_oauth
+
+import (
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"time"
+)
+
+// refreshScope checks that refresh token is used by the client it was issued to and resolves scope of rotated tokens,
+// requested scope may only narrow scope of the grant, empty requested scope keeps it (RFC 6749 6)
+func refreshScope(access *model.AccessTokens, client model.Clients, requested string) (string, error) {
+	if access.ClientId != client.ID {
+		return "", errors.New(util.InvalidRefreshToken)
+	}
+	scope, err := exchangeScope(access.Scope, requested)
+	if err != nil {
+		return "", errors.New(util.InvalidScope)
+	}
+	return scope, nil
+}
+
+// refreshExpired reports whether refresh token expired by expiry embedded in the token or persisted with it,
+// tokens issued before refresh tokens expired carry none and never expire
+func refreshExpired(payload *model.RefreshTokenPayload, refreshToken model.RefreshTokens) bool {
+	now := time.Now().Unix()
+	return (payload.ExpiredAt != 0 && payload.ExpiredAt < now) || (refreshToken.ExpiredAt != 0 && refreshToken.ExpiredAt < now)
+}
+
+// GetByRefresh use the refresh token for token information data,
+// refresh Refresh token string, the refresh token and its access token are revoked after one time use,
+// presenting already used refresh token revokes its whole family and emits util.EventRefreshTokenReuse
+func (m *Manager) GetByRefresh(refresh string) (*model.AccessTokens, error) {
+	accessTokenData, _, err := m.useRefreshToken(refresh, nil)
+	return accessTokenData, err
+}
+
+// RotateRefreshToken exchanges refresh token for new access token and refresh token of the same family,
+// info holds client credentials, requested scope and token lifetimes, user is taken from the refresh token,
+// refresh token must be used by client it was issued to and scope may only be narrowed
+func (m *Manager) RotateRefreshToken(refresh string, info model.TokenInfo) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	client, err := m.authenticateInfo(info, util.GrantRefreshToken)
+	if err != nil {
+		return tokenResp, err
+	}
+	var scope string
+	accessTokenData, refreshToken, err := m.useRefreshToken(refresh, func(access *model.AccessTokens) error {
+		scope, err = refreshScope(access, client, info.GetScope())
+		return err
+	})
+	if err != nil {
+		return tokenResp, err
+	}
+	info.SetUserID(accessTokenData.UserId)
+	info.SetScope(scope)
+	return m.insertTokens(info, refreshToken.FamilyId)
+}
+
+// InspectRefresh validates refresh token and returns its access token and refresh token without using it,
+// used refresh token is reported as revoked without revoking its family as nobody tries to redeem it
+func (m *Manager) InspectRefresh(refresh string) (*model.AccessTokens, *model.RefreshTokens, error) {
+	accessToken, refreshToken, err := m.findRefreshToken(refresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	if refreshToken.Revoked {
+		return nil, nil, errors.New(util.RefreshTokenRevoked)
+	}
+	if refreshExpired(accessToken, *refreshToken) {
+		return nil, nil, errors.New(util.RefreshTokenExpired)
+	}
+	accessTokenData, err := m.refreshAccessToken(accessToken.AccessTokenId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return accessTokenData, refreshToken, nil
+}
+
+// findRefreshToken decodes refresh token and loads its row
+func (m *Manager) findRefreshToken(refresh string) (*model.RefreshTokenPayload, *model.RefreshTokens, error) {
+	accessToken, err := m.decodeRefreshToken(refresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, err := m.store.GetRefreshToken(accessToken.AccessTokenId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return accessToken, &refreshToken, nil
+}
+
+// refreshAccessToken loads access token refresh token was issued with, refresh token is invalid once it is revoked or gone
+func (m *Manager) refreshAccessToken(accessTokenId uuid.UUID) (*model.AccessTokens, error) {
+	accessTokenData, err := m.store.GetAccessToken(accessTokenId)
+	if err != nil {
+		if err.Error() == util.InvalidAccessToken {
+			return nil, errors.New(util.InvalidRefreshToken)
+		}
+		return nil, err
+	}
+	if accessTokenData.Revoked {
+		return nil, errors.New(util.InvalidRefreshToken)
+	}
+	return &accessTokenData, nil
+}
+
+// useRefreshToken revokes refresh token and its access token after one time use and returns them,
+// check may reject the token before it is used, already used refresh token revokes its family
+func (m *Manager) useRefreshToken(refresh string, check func(access *model.AccessTokens) error) (*model.AccessTokens, *model.RefreshTokens, error) {
+	accessToken, refreshToken, err := m.findRefreshToken(refresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	if refreshToken.Revoked {
+		return nil, nil, m.revokeFamily(*refreshToken)
+	}
+	if refreshExpired(accessToken, *refreshToken) {
+		return nil, nil, errors.New(util.RefreshTokenExpired)
+	}
+	accessTokenData, err := m.refreshAccessToken(accessToken.AccessTokenId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if check != nil {
+		if err := check(accessTokenData); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// revoke refresh token after one time use, losing the race to concurrent use counts as reuse
+	used, err := m.store.UseRefreshToken(refreshToken.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !used {
+		return nil, nil, m.revokeFamily(*refreshToken)
+	}
+
+	// revoke associated access token after use
+	err = m.store.RevokeAccessToken(accessToken.AccessTokenId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return accessTokenData, refreshToken, nil
+}
+
+// revokeFamily revokes every refresh token of the family of reused refresh token and their access tokens,
+// emits util.EventRefreshTokenReuse and returns error for the reused token
+func (m *Manager) revokeFamily(refreshToken model.RefreshTokens) error {
+	event := model.SecurityEvent{
+		Type:      util.EventRefreshTokenReuse,
+		FamilyId:  refreshToken.FamilyId,
+		CreatedAt: time.Now(),
+	}
+	accessTokens, err := m.store.RevokeRefreshFamily(refreshToken.FamilyId)
+	if err != nil {
+		return err
+	}
+	for _, access := range accessTokens {
+		event.UserId = access.UserId
+		event.ClientId = access.ClientId
+	}
+	m.emit(event)
+	return errors.New(util.RefreshTokenRevoked)
+}
@@ -0,0 +1,248 @@
+package golang_oauth
+
+import (
+	"database/sql"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRefreshRotation runs refresh token rotation and reuse detection against given store
+func testRefreshRotation(t *testing.T, store *Manager) {
+	var events []model.SecurityEvent
+	store.SetSecurityEventHandler(func(event model.SecurityEvent) {
+		events = append(events, event)
+	})
+	defer store.SetSecurityEventHandler(nil)
+
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	info := func(scope string) *model.Token {
+		return &model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			UserID:          userID,
+			Scope:           scope,
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+			RefreshCreateAt: time.Now(),
+		}
+	}
+	first, err := store.Create(info("read write"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	other, err := store.CreateClient(userID, "other")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = store.RotateRefreshToken(first.RefreshToken, &model.Token{ClientID: other.ID, ClientSecret: other.Secret})
+	if err == nil || err.Error() != util.InvalidRefreshToken {
+		t.Errorf("expected %s, got %v", util.InvalidRefreshToken, err)
+	}
+	if _, err := store.RotateRefreshToken(first.RefreshToken, info("read admin")); err == nil || err.Error() != util.InvalidScope {
+		t.Errorf("expected %s, got %v", util.InvalidScope, err)
+	}
+
+	// rejected requests do not use the refresh token up
+	second, err := store.RotateRefreshToken(first.RefreshToken, info("read"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	access, err := store.GetByAccess(second.AccessToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if access.UserId != userID || access.Scope != "read" {
+		t.Errorf("unexpected access token %+v", access)
+	}
+	if _, err := store.GetByAccess(first.AccessToken); err == nil {
+		t.Error("access token of used refresh token must be revoked")
+	}
+	third, err := store.RotateRefreshToken(second.RefreshToken, info(""))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(events) != 0 {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	// used refresh tokens survive gc while their family is alive
+	if _, err := store.Store().Clean(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.RotateRefreshToken(first.RefreshToken, info("")); err == nil || err.Error() != util.RefreshTokenRevoked {
+		t.Errorf("expected %s, got %v", util.RefreshTokenRevoked, err)
+	}
+	if len(events) != 1 || events[0].Type != util.EventRefreshTokenReuse || events[0].UserId != userID || events[0].ClientId != client.ID {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if _, err := store.GetByAccess(third.AccessToken); err == nil {
+		t.Error("access tokens of reused family must be revoked")
+	}
+	if _, err := store.GetByRefresh(third.RefreshToken); err == nil || err.Error() != util.RefreshTokenRevoked {
+		t.Errorf("expected %s, got %v", util.RefreshTokenRevoked, err)
+	}
+
+	// other families are not affected
+	unrelated, err := store.Create(info("read"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByRefresh(unrelated.RefreshToken); err != nil {
+		t.Error(err.Error())
+	}
+
+	// refresh token revoked on logout is not taken for reuse of a rotated one
+	loggedOut, err := store.Create(info("read"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	loggedOutAccess, err := store.GetByAccess(loggedOut.AccessToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reported := len(events)
+	if err := store.RevokeRefreshToken(loggedOutAccess.ID.String()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.RotateRefreshToken(loggedOut.RefreshToken, info("")); err == nil || err.Error() != util.InvalidRefreshToken {
+		t.Errorf("expected %s, got %v", util.InvalidRefreshToken, err)
+	}
+	if len(events) != reported {
+		t.Errorf("revoked refresh token must not be reported as reused, got %+v", events[reported:])
+	}
+	if _, err := store.GetByAccess(loggedOut.AccessToken); err != nil {
+		t.Errorf("access token of revoked refresh token must stay valid, got %v", err)
+	}
+}
+
+// testRefreshExpiry runs refresh token expiry and client default lifetimes against given store
+func testRefreshExpiry(t *testing.T, store *Manager) {
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientTokenLifetime(client.ID, time.Minute, time.Hour); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientTokenLifetime(uuid.New(), time.Minute, time.Hour); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s, got %v", util.InvalidClient, err)
+	}
+
+	// lifetimes missing from the request default to those of the client
+	now := time.Now().Unix()
+	resp, err := store.Create(&model.Token{ClientID: client.ID, ClientSecret: client.Secret, UserID: userID})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if resp.ExpiredAt < now+60 || resp.ExpiredAt > now+61 || resp.RefreshExpiredAt < now+3600 || resp.RefreshExpiredAt > now+3601 {
+		t.Errorf("unexpected expiry %+v", resp)
+	}
+	if _, err := store.GetByRefresh(resp.RefreshToken); err != nil {
+		t.Error(err.Error())
+	}
+
+	expired, err := store.Create(&model.Token{
+		ClientID:         client.ID,
+		ClientSecret:     client.Secret,
+		UserID:           userID,
+		RefreshCreateAt:  time.Now().Add(-time.Hour * 2),
+		RefreshExpiresIn: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByRefresh(expired.RefreshToken); err == nil || err.Error() != util.RefreshTokenExpired {
+		t.Errorf("expected %s, got %v", util.RefreshTokenExpired, err)
+	}
+	_, err = store.RotateRefreshToken(expired.RefreshToken, &model.Token{ClientID: client.ID, ClientSecret: client.Secret})
+	if err == nil || err.Error() != util.RefreshTokenExpired {
+		t.Errorf("expected %s, got %v", util.RefreshTokenExpired, err)
+	}
+	if _, err := store.GetByAccess(expired.AccessToken); err != nil {
+		t.Error("rejecting expired refresh token must not revoke its access token")
+	}
+}
+
+func TestRefreshExpiryInPayload(t *testing.T) {
+	backend := NewDefaultMemoryStore()
+	store := NewManager(backend)
+	defer store.Close()
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := store.Create(&model.Token{ClientID: client.ID, ClientSecret: client.Secret, UserID: userID,
+		RefreshCreateAt: time.Now(), RefreshExpiresIn: time.Hour})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	payload, err := store.decodeRefreshToken(resp.RefreshToken)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if payload.ExpiredAt != resp.RefreshExpiredAt {
+		t.Errorf("expected expiry %d in payload, got %d", resp.RefreshExpiredAt, payload.ExpiredAt)
+	}
+
+	// expiry embedded in the token is enforced even when the stored one is not
+	backend.mu.Lock()
+	for id, item := range backend.refresh {
+		item.ExpiredAt = 0
+		backend.refresh[id] = item
+	}
+	backend.mu.Unlock()
+	payload.ExpiredAt = time.Now().Add(-time.Minute).Unix()
+	forged, err := store.encrypt(payload)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByRefresh(forged); err == nil || err.Error() != util.RefreshTokenExpired {
+		t.Errorf("expected %s, got %v", util.RefreshTokenExpired, err)
+	}
+}
+
+func TestMigrateRefreshFamily(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golang-oauth")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "oauth.db")
+
+	// refresh tokens table as created by earlier versions of the store
+	db, err := sql.Open(util.DriverSQLite, path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = db.Exec("CREATE TABLE oauth_refresh_tokens (id varchar(255) not null primary key, created_at datetime, updated_at datetime, " +
+		"access_token_id varchar(255), revoked integer)")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	id := uuid.New()
+	_, err = db.Exec("INSERT INTO oauth_refresh_tokens VALUES (?, ?, ?, ?, 0)", id.String(), time.Now(), time.Now(), uuid.New().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_ = db.Close()
+
+	store := NewDefaultStore(NewSQLiteConfig(path))
+	defer store.Close()
+	var refreshToken model.RefreshTokens
+	if err := store.db.SelectOne(&refreshToken, "SELECT * FROM oauth_refresh_tokens WHERE id=?", id); err != nil {
+		t.Fatal(err.Error())
+	}
+	if refreshToken.FamilyId != id || refreshToken.ExpiredAt != 0 {
+		t.Errorf("unexpected migrated refresh token %+v", refreshToken)
+	}
+}
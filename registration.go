@@ -0,0 +1,277 @@
+package golang_oauth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// registrableGrants are grant types clients may register for
+var registrableGrants = []string{
+	util.GrantAuthorizationCode,
+	util.GrantRefreshToken,
+	util.GrantClientCredentials,
+	util.GrantPassword,
+	util.GrantDeviceCode,
+	util.GrantTokenExchange,
+	util.GrantJWTBearer,
+}
+
+// SetRegistrationScopes sets scopes dynamically registered clients may register for tokens issued to themselves,
+// registration with any other scope is rejected, without them clients register without scope
+func (c *tokenCodec) SetRegistrationScopes(scopes ...string) {
+	c.scopes = scopes
+}
+
+// registrationScope validates scope of client metadata against scopes set by SetRegistrationScopes,
+// "*" allowing any scope is never registrable
+func (c *tokenCodec) registrationScope(scope string) (string, error) {
+	requested := strings.Fields(scope)
+	for _, item := range requested {
+		allowed := false
+		for _, registrable := range c.scopes {
+			allowed = allowed || (item == registrable && item != "*")
+		}
+		if !allowed {
+			return "", errors.New(util.InvalidClientMetadata)
+		}
+	}
+	return strings.Join(requested, " "), nil
+}
+
+// applyClientMetadata validates client metadata (RFC 7591 2) and sets it on client, it returns new secret
+// when authentication method needs one and client had none, secret is removed for methods without it
+func (c *tokenCodec) applyClientMetadata(client *model.Clients, metadata model.ClientMetadata) (string, error) {
+	grantTypes := metadata.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{util.GrantAuthorizationCode}
+	}
+	codeGrant := false
+	for _, grantType := range grantTypes {
+		known := false
+		for _, item := range registrableGrants {
+			known = known || item == grantType
+		}
+		if !known {
+			return "", errors.New(util.UnsupportedGrantType)
+		}
+		codeGrant = codeGrant || grantType == util.GrantAuthorizationCode
+	}
+	if codeGrant || len(metadata.RedirectURIs) > 0 {
+		if err := validateRedirectURIs(metadata.RedirectURIs); err != nil {
+			return "", err
+		}
+	}
+
+	scope, err := c.registrationScope(metadata.Scope)
+	if err != nil {
+		return "", err
+	}
+
+	method := metadata.TokenEndpointAuthMethod
+	if method == "" {
+		method = util.AuthMethodBasic
+	}
+	switch method {
+	case util.AuthMethodBasic, util.AuthMethodPost:
+	case util.AuthMethodPrivateKey:
+		if metadata.JWKS == nil {
+			return "", errors.New(util.InvalidClientMetadata)
+		}
+	case util.AuthMethodNone:
+		// public clients cannot prove who they are to get tokens for themselves
+		for _, grantType := range grantTypes {
+			if grantType == util.GrantClientCredentials || grantType == util.GrantJWTBearer {
+				return "", errors.New(util.InvalidClientMetadata)
+			}
+		}
+	default:
+		return "", errors.New(util.UnsupportedAuthMethod)
+	}
+	idTokenAlg := metadata.IDTokenSignedResponseAlg
+	if idTokenAlg != "" {
+		supported := false
+		for _, item := range c.idTokenAlgorithms() {
+			supported = supported || item == idTokenAlg
+		}
+		if !supported {
+			return "", errors.New(util.InvalidClientMetadata)
+		}
+	}
+	jwks := ""
+	if metadata.JWKS != nil {
+		encoded, err := encodeClientJWKS(*metadata.JWKS)
+		if err != nil {
+			return "", err
+		}
+		jwks = encoded
+	}
+
+	secret := ""
+	if method == util.AuthMethodBasic || method == util.AuthMethodPost {
+		if client.Secret == "" {
+			var err error
+			if secret, err = newClientSecret(client); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		client.Secret = ""
+		client.SecretCreatedAt = 0
+		clearPreviousSecret(client)
+	}
+	client.Name = metadata.ClientName
+	client.Redirect = strings.Join(metadata.RedirectURIs, " ")
+	client.Public = method == util.AuthMethodNone
+	client.Scope = scope
+	client.JWKS = jwks
+	client.GrantTypes = strings.Join(grantTypes, " ")
+	client.AuthMethod = method
+	client.IDTokenAlg = idTokenAlg
+	client.UpdatedAt = time.Now()
+	return secret, nil
+}
+
+// newRegisteredClient builds dynamically registered client (RFC 7591 3.1) owned by given user, 0 for anonymous registration,
+// response carries client secret and registration access token, the token is stored hashed and shown only once
+func (c *tokenCodec) newRegisteredClient(userId int64, metadata model.ClientMetadata) (model.Clients, model.ClientRegistration, error) {
+	client := model.Clients{}
+	client.ID = uuid.New()
+	client.UserId = userId
+	client.CreatedAt = time.Now()
+	secret, err := c.applyClientMetadata(&client, metadata)
+	if err != nil {
+		return client, model.ClientRegistration{}, err
+	}
+	token, err := util.RandomToken(util.RegistrationTokenSize)
+	if err != nil {
+		return client, model.ClientRegistration{}, err
+	}
+	client.RegistrationToken = util.HashToken(token)
+
+	registration := clientRegistration(client)
+	registration.RegistrationAccessToken = token
+	withSecret(&registration, secret)
+	return client, registration, nil
+}
+
+// withSecret sets secret issued to client on registration response, it never expires
+func withSecret(registration *model.ClientRegistration, secret string) {
+	if secret == "" {
+		return
+	}
+	var expiresAt int64
+	registration.ClientSecret = secret
+	registration.ClientSecretExpiresAt = &expiresAt
+}
+
+// clientRegistration builds client information response (RFC 7592 3) of stored client
+func clientRegistration(client model.Clients) model.ClientRegistration {
+	registration := model.ClientRegistration{
+		ClientMetadata: model.ClientMetadata{
+			RedirectURIs:             strings.Fields(client.Redirect),
+			GrantTypes:               strings.Fields(client.GrantTypes),
+			TokenEndpointAuthMethod:  client.AuthMethod,
+			Scope:                    client.Scope,
+			ClientName:               client.Name,
+			IDTokenSignedResponseAlg: client.IDTokenAlg,
+		},
+		ClientId:         client.ID.String(),
+		ClientIdIssuedAt: client.CreatedAt.Unix(),
+	}
+	if client.JWKS != "" {
+		var jwks util.JWKSet
+		if err := json.Unmarshal([]byte(client.JWKS), &jwks); err == nil {
+			registration.JWKS = &jwks
+		}
+	}
+	return registration
+}
+
+// verifyRegistrationToken checks registration access token presented for client in constant time,
+// clients which were not registered dynamically cannot be managed
+func verifyRegistrationToken(client model.Clients, token string) error {
+	if client.ID == uuid.Nil || client.RegistrationToken == "" || token == "" {
+		return errors.New(util.InvalidRegistration)
+	}
+	if subtle.ConstantTimeCompare([]byte(client.RegistrationToken), []byte(util.HashToken(token))) != 1 {
+		return errors.New(util.InvalidRegistration)
+	}
+	return nil
+}
+
+// RegisterClient registers client with given metadata (RFC 7591),
+// userId user's id who registered the client, 0 for anonymous registration
+func (m *Manager) RegisterClient(userId int64, metadata model.ClientMetadata) (model.ClientRegistration, error) {
+	client, registration, err := m.newRegisteredClient(userId, metadata)
+	if err != nil {
+		return registration, err
+	}
+	err = m.store.InsertClient(client)
+	if err != nil {
+		return model.ClientRegistration{}, err
+	}
+	return registration, nil
+}
+
+// registeredClient returns dynamically registered client authenticated by its registration access token
+func (m *Manager) registeredClient(clientId uuid.UUID, registrationToken string) (model.Clients, error) {
+	client, err := m.store.GetClient(clientId)
+	if err != nil {
+		if err.Error() == util.InvalidClient {
+			return client, errors.New(util.InvalidRegistration)
+		}
+		return client, err
+	}
+	return client, verifyRegistrationToken(client, registrationToken)
+}
+
+// GetRegisteredClient returns metadata of dynamically registered client (RFC 7592 2.1)
+func (m *Manager) GetRegisteredClient(clientId uuid.UUID, registrationToken string) (model.ClientRegistration, error) {
+	client, err := m.registeredClient(clientId, registrationToken)
+	if err != nil {
+		return model.ClientRegistration{}, err
+	}
+	return clientRegistration(client), nil
+}
+
+// UpdateRegisteredClient replaces metadata of dynamically registered client (RFC 7592 2.2),
+// response carries new secret only when the authentication method changed to one which needs it
+func (m *Manager) UpdateRegisteredClient(clientId uuid.UUID, registrationToken string, metadata model.ClientMetadata) (model.ClientRegistration, error) {
+	client, err := m.registeredClient(clientId, registrationToken)
+	if err != nil {
+		return model.ClientRegistration{}, err
+	}
+	current := client.Secret
+	secret, err := m.applyClientMetadata(&client, metadata)
+	if err != nil {
+		return model.ClientRegistration{}, err
+	}
+	if err := m.store.UpdateClient(client); err != nil {
+		return model.ClientRegistration{}, err
+	}
+	// secret is issued or removed when the authentication method changed
+	if client.Secret != current {
+		if err := m.store.UpdateClientSecret(client, current); err != nil {
+			return model.ClientRegistration{}, err
+		}
+	}
+	registration := clientRegistration(client)
+	withSecret(&registration, secret)
+	return registration, nil
+}
+
+// DeleteRegisteredClient deletes dynamically registered client (RFC 7592 2.3) together with its access tokens,
+// refresh tokens, authorization codes and device codes
+func (m *Manager) DeleteRegisteredClient(clientId uuid.UUID, registrationToken string) error {
+	client, err := m.registeredClient(clientId, registrationToken)
+	if err != nil {
+		return err
+	}
+	return m.store.DeleteClient(client.ID)
+}
@@ -0,0 +1,217 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+// testRegistration runs dynamic client registration and management against given store
+func testRegistration(t *testing.T, store *Manager) {
+	store.SetRegistrationScopes("read", "write")
+	registration, err := store.RegisterClient(0, model.ClientMetadata{
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{util.GrantAuthorizationCode, util.GrantRefreshToken, util.GrantClientCredentials},
+		Scope:        "read  write",
+		ClientName:   "registered app",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if registration.ClientSecret == "" || registration.RegistrationAccessToken == "" || registration.ClientSecretExpiresAt == nil ||
+		registration.TokenEndpointAuthMethod != util.AuthMethodBasic || registration.Scope != "read write" {
+		t.Fatalf("unexpected registration %+v", registration)
+	}
+	clientId := uuid.MustParse(registration.ClientId)
+	client, err := store.AuthenticateClient(model.ClientCredentials{ClientID: clientId, ClientSecret: registration.ClientSecret})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if client.RegistrationToken == registration.RegistrationAccessToken || !util.IsSecretHash(client.Secret) {
+		t.Error("registration access token and secret must not be stored in plain text")
+	}
+	if !client.AllowsGrant(util.GrantClientCredentials) || client.AllowsGrant(util.GrantPassword) {
+		t.Errorf("unexpected grant types %s", client.GrantTypes)
+	}
+
+	token := registration.RegistrationAccessToken
+	read, err := store.GetRegisteredClient(clientId, token)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if read.ClientName != "registered app" || read.ClientSecret != "" || read.RegistrationAccessToken != "" || len(read.GrantTypes) != 3 {
+		t.Errorf("unexpected client information %+v", read)
+	}
+	if _, err := store.GetRegisteredClient(clientId, "wrong"); err == nil || err.Error() != util.InvalidRegistration {
+		t.Errorf("expected %s, got %v", util.InvalidRegistration, err)
+	}
+	other, err := store.CreateClient(userID, "not registered")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetRegisteredClient(other.ID, ""); err == nil || err.Error() != util.InvalidRegistration {
+		t.Errorf("client which was not registered dynamically must not be managed, got %v", err)
+	}
+
+	// update cannot widen scope beyond registrable scopes
+	_, err = store.UpdateRegisteredClient(clientId, token, model.ClientMetadata{
+		RedirectURIs: []string{testRedirectURI},
+		Scope:        "admin",
+	})
+	if err == nil || err.Error() != util.InvalidClientMetadata {
+		t.Errorf("expected %s, got %v", util.InvalidClientMetadata, err)
+	}
+	if client, err := store.GetClient(clientId); err != nil || client.Scope != "read write" {
+		t.Errorf("rejected update must not change scope, got %+v %v", client, err)
+	}
+
+	// switching to public client drops the secret
+	updated, err := store.UpdateRegisteredClient(clientId, token, model.ClientMetadata{
+		RedirectURIs:            []string{testRedirectURI},
+		TokenEndpointAuthMethod: util.AuthMethodNone,
+		ClientName:              "renamed",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if updated.ClientName != "renamed" || updated.ClientSecret != "" || len(updated.GrantTypes) != 1 {
+		t.Errorf("unexpected client information %+v", updated)
+	}
+	if client, err := store.GetClient(clientId); err != nil || !client.Public || client.Secret != "" {
+		t.Errorf("expected public client, got %+v %v", client, err)
+	}
+
+	invalid := []struct {
+		metadata model.ClientMetadata
+		err      string
+	}{
+		{model.ClientMetadata{}, util.InvalidRedirectURI},
+		{model.ClientMetadata{RedirectURIs: []string{"https://app.example.com/cb#fragment"}}, util.InvalidRedirectURI},
+		{model.ClientMetadata{GrantTypes: []string{"implicit"}}, util.UnsupportedGrantType},
+		{model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}, TokenEndpointAuthMethod: "client_secret_jwt"}, util.UnsupportedAuthMethod},
+		{model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}, TokenEndpointAuthMethod: util.AuthMethodNone}, util.InvalidClientMetadata},
+		{model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}, TokenEndpointAuthMethod: util.AuthMethodPrivateKey}, util.InvalidClientMetadata},
+		{model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}, Scope: "*"}, util.InvalidClientMetadata},
+		{model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}, Scope: "read admin"}, util.InvalidClientMetadata},
+	}
+	for _, item := range invalid {
+		if _, err := store.RegisterClient(0, item.metadata); err == nil || err.Error() != item.err {
+			t.Errorf("expected %s for %+v, got %v", item.err, item.metadata, err)
+		}
+	}
+
+	// private_key_jwt clients have no secret, an empty one must not authenticate them
+	key, err := util.GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	jwks, err := util.NewJWKSet(key.Public())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyClient, err := store.RegisterClient(userID, model.ClientMetadata{
+		GrantTypes:              []string{util.GrantClientCredentials},
+		TokenEndpointAuthMethod: util.AuthMethodPrivateKey,
+		JWKS:                    &jwks,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if keyClient.ClientSecret != "" || keyClient.JWKS == nil || len(keyClient.JWKS.Keys) != 1 {
+		t.Errorf("unexpected registration %+v", keyClient)
+	}
+	if _, err := store.AuthenticateClient(model.ClientCredentials{ClientID: uuid.MustParse(keyClient.ClientId)}); err == nil {
+		t.Error("client without secret must not authenticate with empty secret")
+	}
+
+	// registered grant types are enforced however the client authenticates
+	keyId := uuid.MustParse(keyClient.ClientId)
+	if _, err := store.Create(&model.Token{ClientID: keyId, UserID: userID}); err == nil || err.Error() != util.InvalidClient {
+		t.Errorf("expected %s for secret of private_key_jwt client, got %v", util.InvalidClient, err)
+	}
+	other, err = store.CreateClient(userID, "unregistered")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.Create(&model.Token{ClientID: other.ID, ClientSecret: other.Secret, UserID: userID}); err != nil {
+		t.Errorf("client without registered grant types may use any, got %v", err)
+	}
+	secretClient, err := store.RegisterClient(userID, model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	secretId := uuid.MustParse(secretClient.ClientId)
+	_, err = store.Create(&model.Token{ClientID: secretId, ClientSecret: secretClient.ClientSecret, UserID: userID})
+	if err == nil || err.Error() != util.UnauthorizedClient {
+		t.Errorf("expected %s for unregistered password grant, got %v", util.UnauthorizedClient, err)
+	}
+	_, err = store.CreateDeviceCode(model.DeviceCodeRequest{ClientID: secretId, ClientSecret: secretClient.ClientSecret})
+	if err == nil || err.Error() != util.UnauthorizedClient {
+		t.Errorf("expected %s for unregistered device grant, got %v", util.UnauthorizedClient, err)
+	}
+
+	// assertions authenticate only clients registered for private_key_jwt
+	store.SetAssertionAudience(testAudience)
+	basicClient, err := store.RegisterClient(userID, model.ClientMetadata{GrantTypes: []string{util.GrantClientCredentials}, JWKS: &jwks})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, item := range []struct {
+		clientId string
+		err      string
+	}{{keyClient.ClientId, ""}, {basicClient.ClientId, util.InvalidClient}} {
+		assertion := signAssertion(t, key, util.ES256, jwks.Keys[0].Kid, model.AssertionClaims{
+			Issuer:    item.clientId,
+			Subject:   item.clientId,
+			Audience:  model.Audience{testAudience},
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			ID:        uuid.New().String(),
+		})
+		_, err := store.AuthenticateClient(model.ClientCredentials{ClientAssertionType: util.ClientAssertionTypeJWT, ClientAssertion: assertion})
+		if (item.err == "" && err != nil) || (item.err != "" && (err == nil || err.Error() != item.err)) {
+			t.Errorf("client %s: expected %q, got %v", item.clientId, item.err, err)
+		}
+	}
+
+	// deleting client deletes its tokens
+	passwordClient, err := store.RegisterClient(userID, model.ClientMetadata{
+		GrantTypes: []string{util.GrantPassword, util.GrantRefreshToken},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	passwordId := uuid.MustParse(passwordClient.ClientId)
+	resp, err := store.Create(&model.Token{
+		ClientID:         passwordId,
+		ClientSecret:     passwordClient.ClientSecret,
+		UserID:           userID,
+		AccessCreateAt:   time.Now(),
+		AccessExpiresIn:  time.Minute,
+		RefreshCreateAt:  time.Now(),
+		RefreshExpiresIn: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	token = passwordClient.RegistrationAccessToken
+	if err := store.DeleteRegisteredClient(passwordId, "wrong"); err == nil {
+		t.Error("client must not be deleted with wrong registration access token")
+	}
+	if err := store.DeleteRegisteredClient(passwordId, token); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetClient(passwordId); err == nil {
+		t.Error("deleted client must be gone")
+	}
+	if _, err := store.GetByAccess(resp.AccessToken); err == nil {
+		t.Error("access token of deleted client must be rejected")
+	}
+	if _, err := store.GetByRefresh(resp.RefreshToken); err == nil {
+		t.Error("refresh token of deleted client must be rejected")
+	}
+	if _, err := store.GetRegisteredClient(passwordId, token); err == nil || err.Error() != util.InvalidRegistration {
+		t.Errorf("expected %s, got %v", util.InvalidRegistration, err)
+	}
+}
@@ -0,0 +1,58 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+)
+
+// revocationTarget resolves id of access token the presented access token or refresh token was issued with,
+// hint is token_type_hint of the request and only decides which kind is decoded first,
+// ok is false for tokens which cannot be decoded
+func (c *tokenCodec) revocationTarget(token, hint string) (uuid.UUID, bool) {
+	fromAccess := func() (uuid.UUID, bool) {
+		claims, err := c.decodeAccessToken(token)
+		if err != nil || claims.ID == uuid.Nil {
+			return uuid.Nil, false
+		}
+		return claims.ID, true
+	}
+	fromRefresh := func() (uuid.UUID, bool) {
+		payload, err := c.decodeRefreshToken(token)
+		if err != nil {
+			return uuid.Nil, false
+		}
+		return payload.AccessTokenId, true
+	}
+	first, second := fromAccess, fromRefresh
+	if hint == util.TokenTypeHintRefresh {
+		first, second = fromRefresh, fromAccess
+	}
+	if id, ok := first(); ok {
+		return id, true
+	}
+	return second()
+}
+
+// RevokeToken revokes access token or refresh token issued to client together with its pair (RFC 7009),
+// refresh token is deleted rather than marked revoked so presenting it later is not taken for reuse,
+// invalid tokens and tokens of other clients are ignored so the caller learns nothing about them
+func (m *Manager) RevokeToken(token, tokenTypeHint string, clientId uuid.UUID) error {
+	accessTokenId, ok := m.revocationTarget(token, tokenTypeHint)
+	if !ok {
+		return nil
+	}
+	access, err := m.store.GetAccessToken(accessTokenId)
+	if err != nil {
+		if err.Error() == util.InvalidAccessToken {
+			return nil
+		}
+		return err
+	}
+	if access.ClientId != clientId {
+		return nil
+	}
+	if err := m.store.RevokeAccessToken(accessTokenId); err != nil {
+		return err
+	}
+	return m.store.DeleteRefreshToken(accessTokenId)
+}
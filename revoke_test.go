@@ -0,0 +1,78 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"testing"
+	"time"
+)
+
+// testRevocation runs token revocation against given store
+func testRevocation(t *testing.T, store *Manager) {
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other, err := store.CreateClient(userID, "other")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	create := func(client model.Clients) model.TokenResponse {
+		resp, err := store.Create(&model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			UserID:          userID,
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return resp
+	}
+
+	// access token revokes its refresh token
+	first, second := create(client), create(client)
+	if err := store.RevokeToken(first.AccessToken, "", client.ID); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByAccess(first.AccessToken); err == nil {
+		t.Error("revoked access token must be rejected")
+	}
+	if _, _, err := store.InspectRefresh(first.RefreshToken); err == nil {
+		t.Error("refresh token of revoked access token must be rejected")
+	}
+	if _, err := store.GetByAccess(second.AccessToken); err != nil {
+		t.Errorf("other tokens of client must stay valid, got %v", err)
+	}
+
+	// refresh token revokes its access token, presenting it again is not taken for reuse
+	events := 0
+	store.SetSecurityEventHandler(func(event model.SecurityEvent) {
+		events++
+	})
+	defer store.SetSecurityEventHandler(nil)
+	if err := store.RevokeToken(second.RefreshToken, util.TokenTypeHintRefresh, client.ID); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := store.GetByAccess(second.AccessToken); err == nil {
+		t.Error("access token of revoked refresh token must be rejected")
+	}
+	if _, err := store.GetByRefresh(second.RefreshToken); err == nil || err.Error() != util.InvalidRefreshToken {
+		t.Errorf("expected %s, got %v", util.InvalidRefreshToken, err)
+	}
+	if events != 0 {
+		t.Errorf("revoked refresh token must not be reported as reused, got %d events", events)
+	}
+
+	// tokens of other clients and unknown tokens are ignored
+	third := create(client)
+	for _, token := range []string{third.AccessToken, third.RefreshToken, "unknown"} {
+		if err := store.RevokeToken(token, "", other.ID); err != nil {
+			t.Errorf("expected token to be ignored, got %v", err)
+		}
+	}
+	if _, err := store.GetByAccess(third.AccessToken); err != nil {
+		t.Errorf("token revoked by other client must stay valid, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+)
+
+// Error is OAuth error response (RFC 6749 5.2) with HTTP status it is sent with
+type Error struct {
+	Status      int
+	Code        string
+	Description string
+}
+
+// NewError creates OAuth error, status HTTP status, code error code such as util.ErrorInvalidRequest,
+// description human readable explanation sent as error_description
+func NewError(status int, code, description string) *Error {
+	return &Error{Status: status, Code: code, Description: description}
+}
+
+// Error returns description of the error
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return e.Description
+	}
+	return e.Code
+}
+
+// storeErrors maps errors returned by Manager to OAuth error codes
+var storeErrors = map[string]string{
+	util.InvalidClient:        util.ErrorInvalidClient,
+	util.UnsupportedAssertion: util.ErrorInvalidClient,
+	util.MissingAudience:      util.ErrorInvalidClient,
+	util.UnauthorizedClient:   util.ErrorUnauthorized,
+	util.InvalidScope:         util.ErrorInvalidScope,
+	util.ExcessiveScope:       util.ErrorInvalidScope,
+	util.UnsupportedTokenType: util.ErrorInvalidRequest,
+	util.InvalidAuthCode:      util.ErrorInvalidGrant,
+	util.AuthCodeExpired:      util.ErrorInvalidGrant,
+	util.AuthCodeUsed:         util.ErrorInvalidGrant,
+	util.InvalidRedirectURI:   util.ErrorInvalidGrant,
+	util.InvalidCodeVerifier:  util.ErrorInvalidGrant,
+	util.InvalidRefreshToken:  util.ErrorInvalidGrant,
+	util.RefreshTokenRevoked:  util.ErrorInvalidGrant,
+	util.RefreshTokenExpired:  util.ErrorInvalidGrant,
+	util.InvalidAccessToken:   util.ErrorInvalidGrant,
+	util.AccessTokenExpired:   util.ErrorInvalidGrant,
+	util.AccessTokenRevoked:   util.ErrorInvalidGrant,
+	util.InvalidAssertion:     util.ErrorInvalidGrant,
+	util.AssertionExpired:     util.ErrorInvalidGrant,
+	util.AssertionReplayed:    util.ErrorInvalidGrant,
+	util.InvalidDeviceCode:    util.ErrorInvalidGrant,
+	util.AuthorizationPending: util.AuthorizationPending,
+	util.SlowDown:             util.SlowDown,
+	util.ExpiredToken:         util.ExpiredToken,
+	util.AccessDenied:         util.AccessDenied,
+}
+
+// toError converts error of grant handler to OAuth error, unknown errors become server_error
+// without description so storage failures are not leaked to clients
+func toError(err error) *Error {
+	if oauthErr, ok := err.(*Error); ok {
+		return oauthErr
+	}
+	code, ok := storeErrors[err.Error()]
+	if !ok {
+		return NewError(http.StatusInternalServerError, util.ErrorServerError, "")
+	}
+	status := http.StatusBadRequest
+	if code == util.ErrorInvalidClient {
+		status = http.StatusUnauthorized
+	}
+	description := err.Error()
+	if description == code {
+		description = ""
+	}
+	return NewError(status, code, description)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+	"time"
+)
+
+// AuthorizationCodeGrant exchanges authorization code for tokens (RFC 6749 4.1.3), with PKCE code_verifier (RFC 7636)
+func AuthorizationCodeGrant(manager *oauth.Manager) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		if err := request.Require("code", "redirect_uri"); err != nil {
+			return model.TokenResponse{}, err
+		}
+		return manager.ExchangeAuthCode(request.Form.Get("code"), request.Form.Get("code_verifier"), request.Token())
+	}
+}
+
+// RefreshTokenGrant rotates refresh token (RFC 6749 6), requested scope may only narrow scope of the grant
+func RefreshTokenGrant(manager *oauth.Manager) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		if err := request.Require("refresh_token"); err != nil {
+			return model.TokenResponse{}, err
+		}
+		return manager.RotateRefreshToken(request.Form.Get("refresh_token"), request.Token())
+	}
+}
+
+// ClientCredentialsGrant issues access token to the client itself (RFC 6749 4.4)
+func ClientCredentialsGrant(manager *oauth.Manager) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		return manager.CreateClientToken(request.Token())
+	}
+}
+
+// DeviceCodeGrant polls device authorization (RFC 8628 3.4), authorization_pending and slow_down tell the client to keep polling
+func DeviceCodeGrant(manager *oauth.Manager) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		if err := request.Require("device_code"); err != nil {
+			return model.TokenResponse{}, err
+		}
+		return manager.PollDeviceCode(request.Form.Get("device_code"), request.Token())
+	}
+}
+
+// TokenExchangeGrant swaps subject token for token with reduced scope (RFC 8693 2.1)
+func TokenExchangeGrant(manager *oauth.Manager) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		if err := request.Require("subject_token", "subject_token_type"); err != nil {
+			return model.TokenResponse{}, err
+		}
+		return manager.ExchangeToken(model.TokenExchangeRequest{
+			ClientID:            request.ClientID,
+			ClientSecret:        request.ClientSecret,
+			ClientAssertionType: request.ClientAssertionType,
+			ClientAssertion:     request.ClientAssertion,
+			AuthMethod:          request.AuthMethod,
+			SubjectToken:        request.Form.Get("subject_token"),
+			SubjectTokenType:    request.Form.Get("subject_token_type"),
+			ActorToken:          request.Form.Get("actor_token"),
+			ActorTokenType:      request.Form.Get("actor_token_type"),
+			Scope:               request.Form.Get("scope"),
+			Audience:            request.Form.Get("audience"),
+			AccessCreateAt:      time.Now(),
+		})
+	}
+}
+
+// JWTBearerGrant issues access token for JWT assertion signed by client (RFC 7523 2.1)
+func JWTBearerGrant(manager *oauth.Manager) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		if err := request.Require("assertion"); err != nil {
+			return model.TokenResponse{}, err
+		}
+		return manager.CreateAssertionToken(request.Form.Get("assertion"), request.Token())
+	}
+}
+
+// PasswordGrant issues tokens for resource owner credentials (RFC 6749 4.3), verify checks username and password
+// and returns id of the user, it is not registered by default as user accounts live outside of the manager
+func PasswordGrant(manager *oauth.Manager, verify func(username, password string) (int64, error)) GrantHandler {
+	return func(request *TokenRequest) (model.TokenResponse, error) {
+		if err := request.Require("username", "password"); err != nil {
+			return model.TokenResponse{}, err
+		}
+		userId, err := verify(request.Form.Get("username"), request.Form.Get("password"))
+		if err != nil || userId == 0 {
+			return model.TokenResponse{}, NewError(http.StatusBadRequest, util.ErrorInvalidGrant, "invalid resource owner credentials")
+		}
+		info := request.Token()
+		info.UserID = userId
+		return manager.Create(info)
+	}
+}
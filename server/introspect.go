@@ -0,0 +1,34 @@
+package server
+
+import (
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+)
+
+// IntrospectionHandler returns token introspection endpoint handler (RFC 7662) for resource servers,
+// the caller authenticates as confidential client with client_secret_basic, client_secret_post or client assertion
+func (s *Server) IntrospectionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			WriteError(w, r, NewError(http.StatusMethodNotAllowed, util.ErrorInvalidRequest, "introspection request must be POST"))
+			return
+		}
+		client, err := s.authenticateClient(r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		// public clients cannot prove who they are, so they may not learn about tokens
+		if client.Public {
+			WriteError(w, r, NewError(http.StatusUnauthorized, util.ErrorInvalidClient, util.InvalidClient))
+			return
+		}
+		token := r.PostForm.Get("token")
+		if token == "" {
+			WriteError(w, r, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, "token is required"))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.manager.Introspect(token, r.PostForm.Get("token_type_hint")))
+	})
+}
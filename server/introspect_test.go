@@ -0,0 +1,82 @@
+package server
+
+import (
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntrospectionHandler(t *testing.T) {
+	store := oauth.NewManager(oauth.NewDefaultMemoryStore())
+	defer store.Close()
+	resourceServer, err := store.CreateClient(userID, "resource server")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	public, err := store.CreateAuthCodeClient(userID, "spa", []string{"https://spa.example.com/callback"}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tokens, err := store.Create(&model.Token{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		UserID:          userID,
+		Scope:           "read",
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	handler := NewServer(store).IntrospectionHandler()
+	id, secret := resourceServer.ID.String(), resourceServer.Secret
+
+	rec := post(handler, util.IntrospectionPath, id, secret, url.Values{"token": {tokens.AccessToken}})
+	var resp model.IntrospectionResponse
+	decode(t, rec, &resp)
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected response %d %v", rec.Code, rec.Header())
+	}
+	if !resp.Active || resp.Scope != "read" || resp.ClientId != client.ID.String() {
+		t.Errorf("unexpected access token introspection %+v", resp)
+	}
+	rec = post(handler, util.IntrospectionPath, id, secret, url.Values{"token": {"unknown"}})
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"active":false}` {
+		t.Errorf("expected inactive token, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// client_secret_post
+	rec = post(handler, util.IntrospectionPath, "", "", url.Values{"token": {"unknown"}, "client_id": {id}, "client_secret": {secret}})
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = post(handler, util.IntrospectionPath, id, "wrong", url.Values{"token": {tokens.AccessToken}})
+	expectError(t, rec, http.StatusUnauthorized, util.ErrorInvalidClient)
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("basic credentials must be challenged")
+	}
+	expectError(t, post(handler, util.IntrospectionPath, "", "", url.Values{"token": {tokens.AccessToken}}),
+		http.StatusUnauthorized, util.ErrorInvalidClient)
+	// public clients cannot authenticate
+	expectError(t, post(handler, util.IntrospectionPath, "", "", url.Values{"token": {tokens.AccessToken}, "client_id": {public.ID.String()}}),
+		http.StatusUnauthorized, util.ErrorInvalidClient)
+	// credentials may not be sent both in header and in form
+	expectError(t, post(handler, util.IntrospectionPath, id, secret, url.Values{"token": {tokens.AccessToken}, "client_secret": {secret}}),
+		http.StatusBadRequest, util.ErrorInvalidRequest)
+	expectError(t, post(handler, util.IntrospectionPath, id, secret, url.Values{}), http.StatusBadRequest, util.ErrorInvalidRequest)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, util.IntrospectionPath, nil))
+	expectError(t, rec, http.StatusMethodNotAllowed, util.ErrorInvalidRequest)
+}
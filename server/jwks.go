@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	oauth "github.com/gobeam/golang-oauth"
+	"net/http"
+	"time"
+)
+
+// defaultJWKSMaxAge is how long clients may cache the key set when no max age is given
+const defaultJWKSMaxAge = time.Hour
+
+// JWKSHandler serves public keys of given provider as JSON Web Key Set, usually mounted at util.JWKSPath,
+// maxAge how long clients may cache the set (default 1 hour), it should be shorter than the time
+// retired keys are kept before KeySet.Prune so clients pick up rotated keys before old ones disappear
+func JWKSHandler(keys oauth.KeyProvider, maxAge time.Duration) http.Handler {
+	if maxAge <= 0 {
+		maxAge = defaultJWKSMaxAge
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		set, err := oauth.JWKS(keys)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(set)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		sum := sha256.Sum256(body)
+		etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write(body)
+	})
+}
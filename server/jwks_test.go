@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto"
+	"encoding/json"
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	keys := oauth.NewKeySet()
+	retired, err := keys.Rotate(util.RS256)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	store := oauth.NewManager(oauth.NewDefaultMemoryStore())
+	defer store.Close()
+	store.SetKeyProvider(keys)
+	if err := store.SetTokenFormat(util.TokenFormatJWT, util.RS256); err != nil {
+		t.Fatal(err.Error())
+	}
+	client, err := store.CreateClient(userID, "jwks app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp, err := store.Create(&model.Token{
+		ClientID:        client.ID,
+		ClientSecret:    client.Secret,
+		UserID:          userID,
+		AccessCreateAt:  time.Now(),
+		AccessExpiresIn: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := keys.Rotate(util.RS256); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := keys.Rotate(util.ES256); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	handler := JWKSHandler(keys, 5*time.Minute)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, util.JWKSPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("unexpected Cache-Control %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	var set util.JWKSet
+	if err := json.Unmarshal(rec.Body.Bytes(), &set); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(set.Keys) != 3 {
+		t.Fatalf("expected active and retired keys, got %d", len(set.Keys))
+	}
+	for _, jwk := range set.Keys {
+		if jwk.Kid == "" || jwk.Use != "sig" || (jwk.Alg != util.RS256 && jwk.Alg != util.ES256) {
+			t.Errorf("unexpected key %+v", jwk)
+		}
+	}
+
+	// token signed by retired key is verifiable by downstream services with published keys only
+	if _, err := util.ParseJWT(resp.AccessToken, &model.AccessTokenClaims{}, func(header util.JWTHeader) (crypto.PublicKey, error) {
+		for _, jwk := range set.Keys {
+			if jwk.Kid == header.Kid {
+				return jwk.PublicKey()
+			}
+		}
+		t.Fatalf("key %s is not published", header.Kid)
+		return nil, nil
+	}); err != nil {
+		t.Error(err.Error())
+	}
+	published := false
+	for _, jwk := range set.Keys {
+		published = published || jwk.Kid == retired.ID
+	}
+	if !published {
+		t.Errorf("retired key %s is not published", retired.ID)
+	}
+
+	// unchanged key set is revalidated with ETag
+	req := httptest.NewRequest(http.MethodGet, util.JWKSPath, nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", rec.Code)
+	}
+
+	// rotation changes ETag
+	if _, err := keys.Rotate(util.RS256); err != nil {
+		t.Fatal(err.Error())
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 after rotation, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, util.JWKSPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", rec.Code)
+	}
+}
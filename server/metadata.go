@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// metadataMaxAge is how long clients may cache server metadata
+const metadataMaxAge = time.Hour
+
+// SetAuthorizationEndpoint sets path of authorization endpoint served by the application, it is advertised
+// in server metadata together with code response type, empty path removes it
+func (s *Server) SetAuthorizationEndpoint(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizePath = path
+}
+
+// SetDeviceAuthorizationEndpoint sets path of device authorization endpoint (RFC 8628 3.1) served by the application,
+// empty path removes it
+func (s *Server) SetDeviceAuthorizationEndpoint(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devicePath = path
+}
+
+// SetScopes sets scopes advertised in server metadata besides OpenID Connect scopes of the manager
+func (s *Server) SetScopes(scopes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scopes = scopes
+}
+
+// ServeJWKS serves public keys of given provider at util.JWKSPath and advertises it as jwks_uri,
+// maxAge how long clients may cache the set, see JWKSHandler
+func (s *Server) ServeJWKS(keys oauth.KeyProvider, maxAge time.Duration) {
+	s.mux.Handle(util.JWKSPath, JWKSHandler(keys, maxAge))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwksPath = util.JWKSPath
+}
+
+// Metadata builds server metadata from configuration of the manager, endpoints served by the server and
+// registered grants, a grant is listed only when its handler is registered and the endpoint or manager
+// setting it depends on is present, endpoint urls are issuer identifier followed by endpoint path so
+// the server must be mounted at the root of the issuer
+func (s *Server) Metadata() model.ServerMetadata {
+	metadata := s.manager.Metadata()
+	endpoint := func(path string) string {
+		if path == "" {
+			return ""
+		}
+		return metadata.Issuer + path
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metadata.TokenEndpoint = endpoint(util.TokenPath)
+	metadata.IntrospectionEndpoint = endpoint(util.IntrospectionPath)
+	metadata.RevocationEndpoint = endpoint(util.RevocationPath)
+	metadata.JWKSURI = endpoint(s.jwksPath)
+	metadata.RegistrationEndpoint = endpoint(s.registrationPath)
+	if len(metadata.IDTokenSigningAlgValuesSupported) > 0 {
+		metadata.UserInfoEndpoint = endpoint(util.UserInfoPath)
+	}
+	// the manager lists private_key_jwt only once assertion audience is set, JWT bearer grant needs it too
+	assertions := false
+	for _, method := range metadata.TokenEndpointAuthMethodsSupported {
+		assertions = assertions || method == util.AuthMethodPrivateKey
+	}
+	served := map[string]bool{
+		util.GrantAuthorizationCode: s.authorizePath != "",
+		util.GrantDeviceCode:        s.devicePath != "",
+		util.GrantJWTBearer:         assertions,
+	}
+	for grantType := range s.grants {
+		if ok, depends := served[grantType]; depends && !ok {
+			continue
+		}
+		metadata.GrantTypesSupported = append(metadata.GrantTypesSupported, grantType)
+	}
+	sort.Strings(metadata.GrantTypesSupported)
+	if _, ok := s.grants[util.GrantAuthorizationCode]; ok && s.authorizePath != "" {
+		metadata.AuthorizationEndpoint = endpoint(s.authorizePath)
+		metadata.ResponseTypesSupported = []string{util.ResponseTypeCode}
+	} else {
+		metadata.CodeChallengeMethodsSupported = nil
+	}
+	if _, ok := s.grants[util.GrantDeviceCode]; ok {
+		metadata.DeviceAuthorizationEndpoint = endpoint(s.devicePath)
+	}
+	for _, scope := range s.scopes {
+		found := false
+		for _, item := range metadata.ScopesSupported {
+			found = found || item == scope
+		}
+		if !found {
+			metadata.ScopesSupported = append(metadata.ScopesSupported, scope)
+		}
+	}
+	return metadata
+}
+
+// MetadataHandler returns handler of server metadata mounted at util.ServerMetadataPath (RFC 8414 3)
+// and util.OpenIDConfigPath (OpenID Connect Discovery 4), metadata is not served until issuer of the manager is set
+func (s *Server) MetadataHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		metadata := s.Metadata()
+		if metadata.Issuer == "" {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := json.Marshal(metadata)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(metadataMaxAge.Seconds())))
+		_, _ = w.Write(body)
+	})
+}
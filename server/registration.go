@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+)
+
+// registrationErrors maps errors returned by Manager for client metadata to registration error codes (RFC 7591 3.2.2)
+var registrationErrors = map[string]string{
+	util.InvalidRedirectURI:    util.ErrorInvalidRedirect,
+	util.InvalidClientMetadata: util.ErrorInvalidMetadata,
+	util.UnsupportedGrantType:  util.ErrorInvalidMetadata,
+	util.UnsupportedAuthMethod: util.ErrorInvalidMetadata,
+	util.InvalidJWKS:           util.ErrorInvalidMetadata,
+}
+
+// EnableRegistration serves dynamic client registration (RFC 7591) at util.RegistrationPath and client configuration
+// endpoint (RFC 7592) below it, authorize checks registration request, for example its initial access token,
+// and returns id of the user who owns the client, nil authorize allows open registration of clients without owner
+func (s *Server) EnableRegistration(authorize func(r *http.Request) (int64, error)) {
+	s.mux.Handle(util.RegistrationPath, s.registerHandler(authorize))
+	s.mux.Handle(util.RegistrationPath+"/", s.clientConfigurationHandler())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registrationPath = util.RegistrationPath
+}
+
+// registerHandler registers client with metadata of JSON request body
+func (s *Server) registerHandler(authorize func(r *http.Request) (int64, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			WriteError(w, r, NewError(http.StatusMethodNotAllowed, util.ErrorInvalidRequest, "registration request must be POST"))
+			return
+		}
+		var userId int64
+		if authorize != nil {
+			var err error
+			if userId, err = authorize(r); err != nil {
+				writeBearerError(w, util.ErrorInvalidToken, err.Error())
+				return
+			}
+		}
+		var metadata model.ClientMetadata
+		if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+			WriteError(w, r, NewError(http.StatusBadRequest, util.ErrorInvalidMetadata, "request body must be client metadata"))
+			return
+		}
+		registration, err := s.manager.RegisterClient(userId, metadata)
+		if err != nil {
+			writeRegistrationError(w, r, err)
+			return
+		}
+		registration.RegistrationClientURI = s.clientConfigurationURI(r, registration.ClientId)
+		writeJSON(w, http.StatusCreated, registration)
+	})
+}
+
+// clientConfigurationHandler reads, updates and deletes client at util.RegistrationPath/{client_id},
+// requests are authorized by registration access token sent as bearer token
+func (s *Server) clientConfigurationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		clientId, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, util.RegistrationPath+"/"))
+		if token == "" || err != nil {
+			writeBearerError(w, util.ErrorInvalidToken, util.InvalidRegistration)
+			return
+		}
+		var registration model.ClientRegistration
+		switch r.Method {
+		case http.MethodGet:
+			registration, err = s.manager.GetRegisteredClient(clientId, token)
+		case http.MethodPut:
+			var request model.ClientRegistration
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				WriteError(w, r, NewError(http.StatusBadRequest, util.ErrorInvalidMetadata, "request body must be client metadata"))
+				return
+			}
+			if request.ClientId != clientId.String() {
+				WriteError(w, r, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, "client_id does not match"))
+				return
+			}
+			registration, err = s.manager.UpdateRegisteredClient(clientId, token, request.ClientMetadata)
+		case http.MethodDelete:
+			if err = s.manager.DeleteRegisteredClient(clientId, token); err == nil {
+				w.Header().Set("Cache-Control", "no-store")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			WriteError(w, r, NewError(http.StatusMethodNotAllowed, util.ErrorInvalidRequest, "unsupported method"))
+			return
+		}
+		if err != nil {
+			writeRegistrationError(w, r, err)
+			return
+		}
+		registration.RegistrationClientURI = s.clientConfigurationURI(r, registration.ClientId)
+		writeJSON(w, http.StatusOK, registration)
+	})
+}
+
+// clientConfigurationURI returns url of client configuration endpoint of client, under issuer when it is set
+// or under host of the request otherwise
+func (s *Server) clientConfigurationURI(r *http.Request, clientId string) string {
+	base := s.manager.Metadata().Issuer
+	if base == "" {
+		scheme := "https"
+		if r.TLS == nil {
+			scheme = "http"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + util.RegistrationPath + "/" + clientId
+}
+
+// writeRegistrationError writes registration error response, invalid registration access token is challenged (RFC 7592 2)
+func writeRegistrationError(w http.ResponseWriter, r *http.Request, err error) {
+	if err.Error() == util.InvalidRegistration {
+		writeBearerError(w, util.ErrorInvalidToken, err.Error())
+		return
+	}
+	if code, ok := registrationErrors[err.Error()]; ok {
+		WriteError(w, r, NewError(http.StatusBadRequest, code, err.Error()))
+		return
+	}
+	WriteError(w, r, err)
+}
+
+// writeBearerError writes bearer token error (RFC 6750 3), insufficient_scope is 403 and other errors 401
+func writeBearerError(w http.ResponseWriter, code, description string) {
+	status := http.StatusUnauthorized
+	if code == util.ErrorInsufficientScope {
+		status = http.StatusForbidden
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="oauth", error="%s", error_description="%s"`, code, headerDescription(description)))
+	writeJSON(w, status, model.ErrorResponse{Error: code, Description: description})
+}
+
+// headerDescription drops characters error_description may not carry in WWW-Authenticate header (RFC 6750 3),
+// only printable ASCII without double quote and backslash is kept so description cannot break out of quoted value
+func headerDescription(description string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, description)
+}
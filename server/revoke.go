@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+)
+
+// RevocationHandler returns token revocation endpoint handler (RFC 7009) for clients logging out,
+// confidential clients authenticate like for introspection, public clients send their client_id only,
+// the response is the same whether the token existed or not
+func (s *Server) RevocationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			WriteError(w, r, NewError(http.StatusMethodNotAllowed, util.ErrorInvalidRequest, "revocation request must be POST"))
+			return
+		}
+		client, err := s.authenticateClient(r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		token := r.PostForm.Get("token")
+		if token == "" {
+			WriteError(w, r, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, "token is required"))
+			return
+		}
+		if err := s.manager.RevokeToken(token, r.PostForm.Get("token_type_hint"), client.ID); err != nil {
+			WriteError(w, r, NewError(http.StatusServiceUnavailable, util.ErrorServerError, ""))
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	})
+}
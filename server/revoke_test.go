@@ -0,0 +1,88 @@
+package server
+
+import (
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRevocationHandler(t *testing.T) {
+	store := oauth.NewManager(oauth.NewDefaultMemoryStore())
+	defer store.Close()
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	public, err := store.CreateAuthCodeClient(userID, "spa", []string{"https://spa.example.com/callback"}, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	registration, err := store.RegisterClient(userID, model.ClientMetadata{
+		GrantTypes:              []string{util.GrantPassword},
+		TokenEndpointAuthMethod: util.AuthMethodPost,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	create := func(client model.Clients) model.TokenResponse {
+		resp, err := store.Create(&model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			UserID:          userID,
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return resp
+	}
+	handler := NewServer(store).RevocationHandler()
+	id, secret := client.ID.String(), client.Secret
+
+	tokens := create(client)
+	rec := post(handler, util.RevocationPath, id, secret, url.Values{"token": {tokens.AccessToken}})
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected empty status 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := store.GetByAccess(tokens.AccessToken); err == nil {
+		t.Error("revoked access token must be rejected")
+	}
+	if rec := post(handler, util.RevocationPath, id, secret, url.Values{"token": {"unknown"}}); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("expected empty status 200 for unknown token, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// public client identifies itself by client_id
+	tokens = create(public)
+	if rec := post(handler, util.RevocationPath, "", "", url.Values{"token": {tokens.AccessToken}, "client_id": {public.ID.String()}}); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := store.GetByAccess(tokens.AccessToken); err == nil {
+		t.Error("revoked access token of public client must be rejected")
+	}
+
+	// secret must be sent the way the client registered
+	postClient, err := store.GetClient(uuid.MustParse(registration.ClientId))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	postClient.Secret = registration.ClientSecret
+	tokens = create(postClient)
+	form := url.Values{"token": {tokens.AccessToken}}
+	expectError(t, post(handler, util.RevocationPath, registration.ClientId, registration.ClientSecret, form), http.StatusUnauthorized, util.ErrorInvalidClient)
+	form = url.Values{"token": {tokens.AccessToken}, "client_id": {registration.ClientId}, "client_secret": {registration.ClientSecret}}
+	if rec := post(handler, util.RevocationPath, "", "", form); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	expectError(t, post(handler, util.RevocationPath, id, "wrong", url.Values{"token": {"unknown"}}), http.StatusUnauthorized, util.ErrorInvalidClient)
+	// credentials may not be sent both in header and in form
+	expectError(t, post(handler, util.RevocationPath, id, secret, url.Values{"token": {"unknown"}, "client_secret": {secret}}),
+		http.StatusBadRequest, util.ErrorInvalidRequest)
+	expectError(t, post(handler, util.RevocationPath, id, secret, url.Values{}), http.StatusBadRequest, util.ErrorInvalidRequest)
+}
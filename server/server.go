@@ -0,0 +1,261 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Server is OAuth 2.0 authorization server on plain net/http, it serves token endpoint at util.TokenPath,
+// introspection at util.IntrospectionPath, revocation at util.RevocationPath, userinfo at util.UserInfoPath
+// and server metadata at util.ServerMetadataPath and util.OpenIDConfigPath, more handlers can be added with Handle
+type Server struct {
+	manager          *oauth.Manager
+	mux              *http.ServeMux
+	mu               sync.RWMutex
+	grants           map[string]GrantHandler
+	authorizePath    string
+	devicePath       string
+	jwksPath         string
+	registrationPath string
+	scopes           []string
+}
+
+// TokenRequest is form encoded token request (RFC 6749 3.2) handed to grant handler,
+// client credentials are read by ParseClientCredentials
+type TokenRequest struct {
+	model.ClientCredentials
+	GrantType string
+	Form      url.Values
+	Request   *http.Request
+}
+
+// GrantHandler issues tokens for token request of one grant type, errors of Manager
+// are translated to OAuth errors, return *Error for any other failure the client should see
+type GrantHandler func(request *TokenRequest) (model.TokenResponse, error)
+
+// tokenBody is successful token response (RFC 6749 5.1)
+type tokenBody struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+}
+
+// NewServer creates authorization server for given manager with every grant the manager supports registered,
+// except password grant which needs user verification, see PasswordGrant
+func NewServer(manager *oauth.Manager) *Server {
+	s := &Server{
+		manager: manager,
+		mux:     http.NewServeMux(),
+		grants:  make(map[string]GrantHandler),
+	}
+	s.RegisterGrant(util.GrantAuthorizationCode, AuthorizationCodeGrant(manager))
+	s.RegisterGrant(util.GrantRefreshToken, RefreshTokenGrant(manager))
+	s.RegisterGrant(util.GrantClientCredentials, ClientCredentialsGrant(manager))
+	s.RegisterGrant(util.GrantDeviceCode, DeviceCodeGrant(manager))
+	s.RegisterGrant(util.GrantTokenExchange, TokenExchangeGrant(manager))
+	s.RegisterGrant(util.GrantJWTBearer, JWTBearerGrant(manager))
+
+	s.mux.Handle(util.TokenPath, s.TokenHandler())
+	s.mux.Handle(util.IntrospectionPath, s.IntrospectionHandler())
+	s.mux.Handle(util.RevocationPath, s.RevocationHandler())
+	s.mux.Handle(util.UserInfoPath, s.UserInfoHandler())
+	s.mux.Handle(util.ServerMetadataPath, s.MetadataHandler())
+	s.mux.Handle(util.OpenIDConfigPath, s.MetadataHandler())
+	return s
+}
+
+// RegisterGrant registers handler of grant type, replacing handler registered before, nil handler disables the grant
+func (s *Server) RegisterGrant(grantType string, handler GrantHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if handler == nil {
+		delete(s.grants, grantType)
+		return
+	}
+	s.grants[grantType] = handler
+}
+
+// Handle registers additional handler for given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP dispatches request to the endpoint handler matching its path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// TokenHandler returns token endpoint handler, it can be mounted on its own when other endpoints are not wanted
+func (s *Server) TokenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			WriteError(w, r, NewError(http.StatusMethodNotAllowed, util.ErrorInvalidRequest, "token request must be POST"))
+			return
+		}
+		request, err := parseTokenRequest(r)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		s.mu.RLock()
+		handler, ok := s.grants[request.GrantType]
+		s.mu.RUnlock()
+		if !ok {
+			WriteError(w, r, NewError(http.StatusBadRequest, util.ErrorUnsupportedGrant, ""))
+			return
+		}
+		resp, err := handler(request)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		body := tokenBody{
+			AccessToken:     resp.AccessToken,
+			TokenType:       util.TokenTypeBearer,
+			RefreshToken:    resp.RefreshToken,
+			Scope:           resp.Scope,
+			IssuedTokenType: resp.IssuedTokenType,
+			IDToken:         resp.IDToken,
+		}
+		if expiresIn := resp.ExpiredAt - time.Now().Unix(); expiresIn > 0 {
+			body.ExpiresIn = expiresIn
+		}
+		writeJSON(w, http.StatusOK, body)
+	})
+}
+
+// parseTokenRequest reads grant type and client credentials of form encoded token request
+func parseTokenRequest(r *http.Request) (*TokenRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, err.Error())
+	}
+	request := &TokenRequest{
+		GrantType: r.PostForm.Get("grant_type"),
+		Form:      r.PostForm,
+		Request:   r,
+	}
+	if request.GrantType == "" {
+		return nil, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, "grant_type is required")
+	}
+	credentials, err := ParseClientCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+	request.ClientCredentials = credentials
+	return request, nil
+}
+
+// ParseClientCredentials reads client credentials of form encoded request to token, introspection or revocation endpoint
+// from HTTP basic authentication (client_secret_basic) or client_id and client_secret form parameters (client_secret_post)
+// and client assertion (RFC 7523 2.2), the form must be parsed, credentials may not be sent both in Authorization header
+// and in the form (RFC 6749 2.3), authentication method the secret was sent with is recorded so the manager can enforce it
+func ParseClientCredentials(r *http.Request) (model.ClientCredentials, error) {
+	credentials := model.ClientCredentials{
+		ClientAssertionType: r.PostForm.Get("client_assertion_type"),
+		ClientAssertion:     r.PostForm.Get("client_assertion"),
+		AuthMethod:          util.AuthMethodPost,
+	}
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		credentials.AuthMethod = util.AuthMethodBasic
+		if r.PostForm.Get("client_secret") != "" || credentials.ClientAssertion != "" {
+			return credentials, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, "multiple client authentication methods")
+		}
+		var errId, errSecret error
+		id, errId = url.QueryUnescape(id)
+		secret, errSecret = url.QueryUnescape(secret)
+		if errId != nil || errSecret != nil {
+			return credentials, NewError(http.StatusUnauthorized, util.ErrorInvalidClient, util.InvalidClient)
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != "" {
+		clientId, err := uuid.Parse(id)
+		if err != nil {
+			return credentials, NewError(http.StatusUnauthorized, util.ErrorInvalidClient, util.InvalidClient)
+		}
+		credentials.ClientID = clientId
+	}
+	credentials.ClientSecret = secret
+	return credentials, nil
+}
+
+// authenticateClient authenticates client calling introspection or revocation endpoint with credentials
+// read by ParseClientCredentials, public clients by client_id only
+func (s *Server) authenticateClient(r *http.Request) (model.Clients, error) {
+	if err := r.ParseForm(); err != nil {
+		return model.Clients{}, NewError(http.StatusBadRequest, util.ErrorInvalidRequest, err.Error())
+	}
+	credentials, err := ParseClientCredentials(r)
+	if err != nil {
+		return model.Clients{}, err
+	}
+	if credentials.ClientID == uuid.Nil && credentials.ClientAssertion == "" {
+		return model.Clients{}, NewError(http.StatusUnauthorized, util.ErrorInvalidClient, util.InvalidClient)
+	}
+	return s.manager.AuthenticateClient(credentials)
+}
+
+// Require checks that every given form parameter is present
+func (r *TokenRequest) Require(names ...string) error {
+	for _, name := range names {
+		if r.Form.Get(name) == "" {
+			return NewError(http.StatusBadRequest, util.ErrorInvalidRequest, fmt.Sprintf("%s is required", name))
+		}
+	}
+	return nil
+}
+
+// Token builds token information with client credentials, scope and redirect uri of request,
+// token lifetimes are left to defaults of the client
+func (r *TokenRequest) Token() *model.Token {
+	return &model.Token{
+		ClientID:            r.ClientID,
+		ClientSecret:        r.ClientSecret,
+		ClientAssertionType: r.ClientAssertionType,
+		ClientAssertion:     r.ClientAssertion,
+		AuthMethod:          r.AuthMethod,
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		Scope:               r.Form.Get("scope"),
+	}
+}
+
+// WriteError writes OAuth error response, err which is not *Error is translated from Manager error,
+// clients which sent basic credentials are challenged to retry with them on invalid_client (RFC 6749 5.2)
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	oauthErr := toError(err)
+	if oauthErr.Status == http.StatusUnauthorized {
+		if _, _, basic := r.BasicAuth(); basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+	writeJSON(w, oauthErr.Status, model.ErrorResponse{Error: oauthErr.Code, Description: oauthErr.Description})
+}
+
+// writeJSON writes value as JSON response which must not be cached as it may carry tokens (RFC 6749 5.1)
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
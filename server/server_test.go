@@ -0,0 +1,401 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	oauth "github.com/gobeam/golang-oauth"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var userID int64 = 1
+
+// post sends form to server, authenticated with basic credentials when id is set
+func post(handler http.Handler, path, id, secret string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		req.SetBasicAuth(id, secret)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode decodes JSON body of response
+func decode(t *testing.T, rec *httptest.ResponseRecorder, value interface{}) {
+	if err := json.Unmarshal(rec.Body.Bytes(), value); err != nil {
+		t.Fatalf("invalid body %q: %v", rec.Body.String(), err)
+	}
+}
+
+// expectError checks OAuth error response
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	var body model.ErrorResponse
+	decode(t, rec, &body)
+	if rec.Code != status || body.Error != code {
+		t.Errorf("expected %d %s, got %d %s", status, code, rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("error response must not be cached, got %v", rec.Header())
+	}
+}
+
+func TestTokenHandler(t *testing.T) {
+	store := oauth.NewManager(oauth.NewDefaultMemoryStore())
+	defer store.Close()
+	client, err := store.CreateClient(userID, "app")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := store.SetClientScope(client.ID, "read"); err != nil {
+		t.Fatal(err.Error())
+	}
+	srv := NewServer(store)
+	srv.RegisterGrant(util.GrantPassword, PasswordGrant(store, func(username, password string) (int64, error) {
+		if username != "user@example.com" || password != "secret" {
+			return 0, errors.New("invalid password")
+		}
+		return userID, nil
+	}))
+	id, secret := client.ID.String(), client.Secret
+
+	t.Run("ClientSecretBasic", func(t *testing.T) {
+		rec := post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantClientCredentials}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Cache-Control") != "no-store" || rec.Header().Get("Pragma") != "no-cache" {
+			t.Errorf("token response must not be cached, got %v", rec.Header())
+		}
+		var body map[string]interface{}
+		decode(t, rec, &body)
+		if body["access_token"] == "" || body["token_type"] != util.TokenTypeBearer || body["expires_in"].(float64) <= 0 || body["scope"] != "read" {
+			t.Errorf("unexpected token response %v", body)
+		}
+		if _, ok := body["refresh_token"]; ok {
+			t.Error("client credentials grant must not issue refresh token")
+		}
+	})
+
+	t.Run("PasswordAndRefresh", func(t *testing.T) {
+		rec := post(srv, util.TokenPath, id, secret, url.Values{
+			"grant_type": {util.GrantPassword},
+			"username":   {"user@example.com"},
+			"password":   {"secret"},
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		var tokens struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		decode(t, rec, &tokens)
+
+		// client_secret_post
+		form := url.Values{"grant_type": {util.GrantRefreshToken}, "refresh_token": {tokens.RefreshToken}, "client_id": {id}, "client_secret": {secret}}
+		if rec := post(srv, util.TokenPath, "", "", form); rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		expectError(t, post(srv, util.TokenPath, "", "", form), http.StatusBadRequest, util.ErrorInvalidGrant)
+
+		rec = post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantPassword}, "username": {"user@example.com"}, "password": {"wrong"}})
+		expectError(t, rec, http.StatusBadRequest, util.ErrorInvalidGrant)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		rec := post(srv, util.TokenPath, id, "wrong", url.Values{"grant_type": {util.GrantClientCredentials}})
+		expectError(t, rec, http.StatusUnauthorized, util.ErrorInvalidClient)
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Error("basic credentials must be challenged")
+		}
+		expectError(t, post(srv, util.TokenPath, id, secret, url.Values{}), http.StatusBadRequest, util.ErrorInvalidRequest)
+		expectError(t, post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {"implicit"}}), http.StatusBadRequest, util.ErrorUnsupportedGrant)
+		expectError(t, post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantRefreshToken}}), http.StatusBadRequest, util.ErrorInvalidRequest)
+		assertion := url.Values{"grant_type": {util.GrantClientCredentials}, "client_assertion_type": {util.ClientAssertionTypeJWT}, "client_assertion": {"a.b.c"}}
+		expectError(t, post(srv, util.TokenPath, "", "", assertion), http.StatusUnauthorized, util.ErrorInvalidClient)
+		for _, refresh := range []string{"garbage", "abc.def", "a.b.c"} {
+			expectError(t, post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantRefreshToken}, "refresh_token": {refresh}}),
+				http.StatusBadRequest, util.ErrorInvalidGrant)
+		}
+		expectError(t, post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantClientCredentials}, "scope": {"admin"}}),
+			http.StatusBadRequest, util.ErrorInvalidScope)
+		expectError(t, post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantClientCredentials}, "client_secret": {secret}}),
+			http.StatusBadRequest, util.ErrorInvalidRequest)
+
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, util.TokenPath, nil))
+		expectError(t, rec, http.StatusMethodNotAllowed, util.ErrorInvalidRequest)
+	})
+
+	t.Run("GrantRegistry", func(t *testing.T) {
+		srv.RegisterGrant("urn:example:custom", func(request *TokenRequest) (model.TokenResponse, error) {
+			if request.Form.Get("fail") != "" {
+				return model.TokenResponse{}, errors.New("database is down")
+			}
+			return model.TokenResponse{}, NewError(http.StatusBadRequest, util.ErrorInvalidGrant, "custom grant")
+		})
+		rec := post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {"urn:example:custom"}})
+		expectError(t, rec, http.StatusBadRequest, util.ErrorInvalidGrant)
+
+		// unknown errors are not leaked to clients
+		rec = post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {"urn:example:custom"}, "fail": {"1"}})
+		expectError(t, rec, http.StatusInternalServerError, util.ErrorServerError)
+		if strings.Contains(rec.Body.String(), "database") {
+			t.Errorf("error leaked to client: %s", rec.Body.String())
+		}
+
+		srv.RegisterGrant(util.GrantClientCredentials, nil)
+		rec = post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantClientCredentials}})
+		expectError(t, rec, http.StatusBadRequest, util.ErrorUnsupportedGrant)
+	})
+
+	t.Run("UserInfo", func(t *testing.T) {
+		store.SetClaimsProvider(oauth.ClaimsProviderFunc(func(userId int64, scopes []string) (map[string]interface{}, error) {
+			return map[string]interface{}{"email": "user@example.com"}, nil
+		}))
+		defer store.SetClaimsProvider(nil)
+		token := func(scope string) string {
+			rec := post(srv, util.TokenPath, id, secret, url.Values{
+				"grant_type": {util.GrantPassword},
+				"username":   {"user@example.com"},
+				"password":   {"secret"},
+				"scope":      {scope},
+			})
+			var tokens struct {
+				AccessToken string `json:"access_token"`
+			}
+			decode(t, rec, &tokens)
+			return tokens.AccessToken
+		}
+		userinfo := func(token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, util.UserInfoPath, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := userinfo(token("openid email"))
+		var claims map[string]interface{}
+		decode(t, rec, &claims)
+		if rec.Code != http.StatusOK || claims["sub"] != "1" || claims["email"] != "user@example.com" {
+			t.Errorf("unexpected userinfo response %d %s", rec.Code, rec.Body.String())
+		}
+		if rec := post(srv, util.UserInfoPath, "", "", url.Values{"access_token": {token("openid")}}); rec.Code != http.StatusOK {
+			t.Errorf("expected status 200 for token in form body, got %d %s", rec.Code, rec.Body.String())
+		}
+
+		rec = userinfo(token("email"))
+		expectError(t, rec, http.StatusForbidden, util.ErrorInsufficientScope)
+		rec = userinfo("invalid")
+		expectError(t, rec, http.StatusUnauthorized, util.ErrorInvalidToken)
+		if !strings.Contains(rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+			t.Errorf("invalid token must be challenged, got %v", rec.Header())
+		}
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, util.UserInfoPath, nil))
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("request without token must be challenged, got %d %v", rec.Code, rec.Header())
+		}
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		rec := post(srv, util.IntrospectionPath, id, secret, url.Values{"token": {"unknown"}})
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"active":false}` {
+			t.Errorf("unexpected introspection response %d %s", rec.Code, rec.Body.String())
+		}
+		if rec := post(srv, util.RevocationPath, id, secret, url.Values{"token": {"unknown"}}); rec.Code != http.StatusOK {
+			t.Errorf("unexpected revocation response %d %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	store := oauth.NewManager(oauth.NewDefaultMemoryStore())
+	defer store.Close()
+	srv := NewServer(store)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	if rec := get(util.ServerMetadataPath); rec.Code != http.StatusNotFound {
+		t.Errorf("metadata must not be served without issuer, got %d", rec.Code)
+	}
+
+	store.SetIssuer("https://auth.example.com")
+	store.SetAssertionAudience("https://auth.example.com/token")
+	keys, err := oauth.NewDefaultKeyProvider()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	srv.ServeJWKS(keys, 0)
+	srv.SetAuthorizationEndpoint("/authorize")
+	srv.SetScopes("read", util.ScopeOpenID)
+	srv.RegisterGrant(util.GrantTokenExchange, nil)
+
+	for _, path := range []string{util.ServerMetadataPath, util.OpenIDConfigPath} {
+		rec := get(path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		var metadata model.ServerMetadata
+		decode(t, rec, &metadata)
+		issuer := "https://auth.example.com"
+		for _, pair := range [][2]string{
+			{metadata.Issuer, issuer},
+			{metadata.AuthorizationEndpoint, issuer + "/authorize"},
+			{metadata.TokenEndpoint, issuer + util.TokenPath},
+			{metadata.UserInfoEndpoint, issuer + util.UserInfoPath},
+			{metadata.JWKSURI, issuer + util.JWKSPath},
+			{metadata.RevocationEndpoint, issuer + util.RevocationPath},
+		} {
+			if pair[0] != pair[1] {
+				t.Errorf("expected %s, got %s", pair[1], pair[0])
+			}
+		}
+		joined := func(values []string) string {
+			return strings.Join(values, " ")
+		}
+		if joined(metadata.ResponseTypesSupported) != util.ResponseTypeCode || joined(metadata.ScopesSupported) != "openid read" ||
+			joined(metadata.IDTokenSigningAlgValuesSupported) != util.RS256 {
+			t.Errorf("unexpected metadata %s", rec.Body.String())
+		}
+		if !strings.Contains(joined(metadata.TokenEndpointAuthMethodsSupported), util.AuthMethodPrivateKey) ||
+			strings.Contains(joined(metadata.IntrospectionEndpointAuthMethodsSupported), util.AuthMethodNone) {
+			t.Errorf("unexpected client authentication methods %s", rec.Body.String())
+		}
+		if grants := joined(metadata.GrantTypesSupported); strings.Contains(grants, util.GrantTokenExchange) ||
+			strings.Contains(grants, util.GrantDeviceCode) || !strings.Contains(grants, util.GrantAuthorizationCode) {
+			t.Errorf("metadata must list registered grants only, got %s", grants)
+		}
+		if metadata.DeviceAuthorizationEndpoint != "" {
+			t.Errorf("device authorization endpoint is not served, got %s", metadata.DeviceAuthorizationEndpoint)
+		}
+	}
+	if rec := get(util.JWKSPath); rec.Code != http.StatusOK {
+		t.Errorf("expected JWKS to be served, got %d", rec.Code)
+	}
+
+	// assertions are not advertised until audience is set
+	store.SetAssertionAudience("")
+	metadata := srv.Metadata()
+	for _, item := range append(metadata.GrantTypesSupported, metadata.TokenEndpointAuthMethodsSupported...) {
+		if item == util.GrantJWTBearer || item == util.AuthMethodPrivateKey {
+			t.Errorf("metadata must not list %s without assertion audience", item)
+		}
+	}
+	store.SetAssertionAudience("https://auth.example.com/token")
+	if grants := strings.Join(srv.Metadata().GrantTypesSupported, " "); !strings.Contains(grants, util.GrantJWTBearer) {
+		t.Errorf("metadata must list %s with assertion audience, got %s", util.GrantJWTBearer, grants)
+	}
+
+	// grants are listed only together with the endpoint they depend on
+	srv.SetAuthorizationEndpoint("")
+	srv.SetDeviceAuthorizationEndpoint("/device")
+	metadata = srv.Metadata()
+	grants := strings.Join(metadata.GrantTypesSupported, " ")
+	if strings.Contains(grants, util.GrantAuthorizationCode) || len(metadata.ResponseTypesSupported) != 0 {
+		t.Errorf("metadata must not list %s without authorization endpoint, got %s", util.GrantAuthorizationCode, grants)
+	}
+	if !strings.Contains(grants, util.GrantDeviceCode) || metadata.DeviceAuthorizationEndpoint != "https://auth.example.com/device" {
+		t.Errorf("metadata must list %s with device authorization endpoint, got %s", util.GrantDeviceCode, grants)
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	store := oauth.NewManager(oauth.NewDefaultMemoryStore())
+	defer store.Close()
+	store.SetRegistrationScopes("read")
+	srv := NewServer(store)
+	srv.EnableRegistration(func(r *http.Request) (int64, error) {
+		if bearerToken(r) != "initial" {
+			return 0, errors.New("invalid initial access token")
+		}
+		return userID, nil
+	})
+	send := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+	metadata := `{"client_name":"cli","grant_types":["client_credentials"],"scope":"read"}`
+
+	expectError(t, send(http.MethodPost, util.RegistrationPath, "", metadata), http.StatusUnauthorized, util.ErrorInvalidToken)
+	expectError(t, send(http.MethodPost, util.RegistrationPath, "initial", `{"grant_types":["authorization_code"]}`),
+		http.StatusBadRequest, util.ErrorInvalidRedirect)
+	expectError(t, send(http.MethodPost, util.RegistrationPath, "initial", `{"grant_types":["client_credentials"],"token_endpoint_auth_method":"tls_client_auth"}`),
+		http.StatusBadRequest, util.ErrorInvalidMetadata)
+	expectError(t, send(http.MethodPost, util.RegistrationPath, "initial", `{"grant_types":["client_credentials"],"scope":"admin"}`),
+		http.StatusBadRequest, util.ErrorInvalidMetadata)
+
+	rec := send(http.MethodPost, util.RegistrationPath, "initial", metadata)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d %s", rec.Code, rec.Body.String())
+	}
+	var registration model.ClientRegistration
+	decode(t, rec, &registration)
+	configuration := util.RegistrationPath + "/" + registration.ClientId
+	if registration.RegistrationClientURI != "http://example.com"+configuration || registration.ClientSecret == "" {
+		t.Fatalf("unexpected registration %s", rec.Body.String())
+	}
+	if client, err := store.GetClient(uuid.MustParse(registration.ClientId)); err != nil || client.UserId != userID {
+		t.Errorf("client must be owned by authorized user, got %+v %v", client, err)
+	}
+
+	// only registered grant types may be used
+	id, secret := registration.ClientId, registration.ClientSecret
+	if rec := post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantClientCredentials}}); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = post(srv, util.TokenPath, id, secret, url.Values{"grant_type": {util.GrantDeviceCode}, "device_code": {"code"}})
+	expectError(t, rec, http.StatusBadRequest, util.ErrorUnauthorized)
+	// client registered for client_secret_basic may not send its secret in the form
+	rec = post(srv, util.TokenPath, "", "", url.Values{"grant_type": {util.GrantClientCredentials}, "client_id": {id}, "client_secret": {secret}})
+	expectError(t, rec, http.StatusUnauthorized, util.ErrorInvalidClient)
+
+	token := registration.RegistrationAccessToken
+	if rec := send(http.MethodGet, configuration, token, ""); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), secret) {
+		t.Errorf("unexpected client information %d %s", rec.Code, rec.Body.String())
+	}
+	expectError(t, send(http.MethodGet, configuration, "wrong", ""), http.StatusUnauthorized, util.ErrorInvalidToken)
+
+	update := `{"client_id":"` + id + `","client_name":"renamed","grant_types":["client_credentials","refresh_token"]}`
+	rec = send(http.MethodPut, configuration, token, update)
+	decode(t, rec, &registration)
+	if rec.Code != http.StatusOK || registration.ClientName != "renamed" || len(registration.GrantTypes) != 2 {
+		t.Errorf("unexpected update response %d %s", rec.Code, rec.Body.String())
+	}
+	expectError(t, send(http.MethodPut, configuration, token, `{"client_id":"other"}`), http.StatusBadRequest, util.ErrorInvalidRequest)
+
+	if rec := send(http.MethodDelete, configuration, token, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d %s", rec.Code, rec.Body.String())
+	}
+	expectError(t, send(http.MethodGet, configuration, token, ""), http.StatusUnauthorized, util.ErrorInvalidToken)
+}
+
+func TestBearerErrorHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeBearerError(rec, util.ErrorInvalidToken, "token \"x\", error=\"other\\\"\nX: é")
+	header := rec.Header().Get("WWW-Authenticate")
+	if header != `Bearer realm="oauth", error="invalid_token", error_description="token x, error=otherX: "` {
+		t.Errorf("unexpected WWW-Authenticate header %s", header)
+	}
+	var body model.ErrorResponse
+	decode(t, rec, &body)
+	if body.Description != "token \"x\", error=\"other\\\"\nX: é" {
+		t.Errorf("body keeps description as is, got %q", body.Description)
+	}
+}
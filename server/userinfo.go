@@ -0,0 +1,59 @@
+package server
+
+import (
+	"github.com/gobeam/golang-oauth/util"
+	"net/http"
+	"strings"
+)
+
+// tokenErrors maps errors returned by Manager for access token to bearer token error codes (RFC 6750 3.1)
+var tokenErrors = map[string]string{
+	util.InvalidAccessToken: util.ErrorInvalidToken,
+	util.AccessTokenExpired: util.ErrorInvalidToken,
+	util.AccessTokenRevoked: util.ErrorInvalidToken,
+	util.MissingOpenIDScope: util.ErrorInsufficientScope,
+}
+
+// UserInfoHandler returns userinfo endpoint handler (OpenID Connect Core 5.3), access token is sent
+// in Authorization header or as access_token form parameter of POST request (RFC 6750 2)
+func (s *Server) UserInfoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			WriteError(w, r, NewError(http.StatusMethodNotAllowed, util.ErrorInvalidRequest, "userinfo request must be GET or POST"))
+			return
+		}
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="oauth"`)
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		claims, err := s.manager.UserInfo(token)
+		if err != nil {
+			code, ok := tokenErrors[err.Error()]
+			if !ok {
+				WriteError(w, r, err)
+				return
+			}
+			writeBearerError(w, code, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, claims)
+	})
+}
+
+// bearerToken returns access token of request from Authorization header or form body
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+			return strings.TrimSpace(header[7:])
+		}
+		return ""
+	}
+	if r.Method == http.MethodPost && r.ParseForm() == nil {
+		return r.PostForm.Get("access_token")
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+package golang_oauth
+
+import (
+	"database/sql"
+	"github.com/gobeam/golang-oauth/util"
+	_ "github.com/mattn/go-sqlite3" //sqlite driver for NewStore
+	"gopkg.in/gorp.v2"
+)
+
+// NewSQLiteConfig create sqlite configuration instance,
+// path sqlite database file, sqlite allows single writer so connection pool is limited to one connection
+func NewSQLiteConfig(path string) *Config {
+	config := NewConfig(path)
+	config.Driver = util.DriverSQLite
+	config.MaxOpenConns = 1
+	config.MaxIdleConns = 1
+	return config
+}
+
+// NewSQLiteStoreWithDB create sqlite store instance,
+// db sql.DB opened with sqlite3 driver,
+// GC time interval (in seconds, default 600)
+func NewSQLiteStoreWithDB(db *sql.DB, gcInterval int) *Store {
+	return NewStoreWithDialect(db, gorp.SqliteDialect{}, gcInterval)
+}
@@ -0,0 +1,111 @@
+package golang_oauth
+
+import (
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/google/uuid"
+)
+
+// TokenStore is the storage backend used to persist oauth clients, authorization codes, device authorizations,
+// access tokens and refresh tokens, it holds no token or grant logic so any database or a mock can back Manager,
+// lookups of missing rows fail with the matching util error, for example util.InvalidClient for GetClient
+type TokenStore interface {
+	// InsertClient stores new client, its secret is already hashed
+	InsertClient(client model.Clients) error
+
+	// GetClient returns client of given id, util.InvalidClient when there is none
+	GetClient(clientId uuid.UUID) (model.Clients, error)
+
+	// UpdateClient saves every column of client except its secrets: name, redirect uris, public flag, scope, JWKS,
+	// grant types, authentication method, ID token algorithm and token lifetimes
+	UpdateClient(client model.Clients) error
+
+	// UpdateClientSecret saves secret of client and secret it replaced, only when the stored secret is still current,
+	// otherwise it fails with util.ConcurrentRotation so concurrent rotations do not both succeed
+	UpdateClientSecret(client model.Clients, current string) error
+
+	// DeleteClient deletes client together with its access tokens, refresh tokens, authorization codes and device codes
+	DeleteClient(clientId uuid.UUID) error
+
+	// UseJTI records id of assertion used by client until expiredAt, util.AssertionReplayed when the client already used it
+	UseJTI(clientId uuid.UUID, jti string, expiredAt int64) error
+
+	// InsertAccessToken stores new access token
+	InsertAccessToken(access model.AccessTokens) error
+
+	// GetAccessToken returns access token of given id, util.InvalidAccessToken when there is none
+	GetAccessToken(id uuid.UUID) (model.AccessTokens, error)
+
+	// RevokeAccessToken marks access token of given id revoked
+	RevokeAccessToken(id uuid.UUID) error
+
+	// RevokeByAccessTokens marks every access token of given user revoked
+	RevokeByAccessTokens(userId int64) error
+
+	// ClearByAccessToken deletes every access token of given user together with its refresh token
+	ClearByAccessToken(userId int64) error
+
+	// InsertRefreshToken stores new refresh token
+	InsertRefreshToken(refresh model.RefreshTokens) error
+
+	// GetRefreshToken returns refresh token issued alongside given access token, util.InvalidRefreshToken when there is none
+	GetRefreshToken(accessTokenId uuid.UUID) (model.RefreshTokens, error)
+
+	// UseRefreshToken marks refresh token of given id revoked, false when it already was
+	UseRefreshToken(id uuid.UUID) (bool, error)
+
+	// RevokeRefreshFamily marks every refresh token of given family and their access tokens revoked
+	// and returns those access tokens
+	RevokeRefreshFamily(familyId uuid.UUID) ([]model.AccessTokens, error)
+
+	// DeleteRefreshToken deletes refresh token issued alongside given access token
+	DeleteRefreshToken(accessTokenId uuid.UUID) error
+
+	// InsertAuthCode stores new authorization code
+	InsertAuthCode(code model.AuthCodes) error
+
+	// GetAuthCode returns authorization code of given id, util.InvalidAuthCode when there is none
+	GetAuthCode(id uuid.UUID) (model.AuthCodes, error)
+
+	// UseAuthCode marks authorization code of given id revoked, false when it already was
+	UseAuthCode(id uuid.UUID) (bool, error)
+
+	// InsertDeviceCode stores new device authorization, it fails when its user code is already in use
+	InsertDeviceCode(code model.DeviceCodes) error
+
+	// GetDeviceCode returns device authorization of given id, util.InvalidDeviceCode when there is none
+	GetDeviceCode(id uuid.UUID) (model.DeviceCodes, error)
+
+	// GetDeviceCodeByUserCode returns device authorization of given normalized user code, util.InvalidUserCode when there is none
+	GetDeviceCodeByUserCode(userCode string) (model.DeviceCodes, error)
+
+	// ApproveDeviceCode approves device authorization of given normalized user code on behalf of user
+	// while it is pending, util.InvalidUserCode otherwise
+	ApproveDeviceCode(userCode string, userId int64) error
+
+	// DenyDeviceCode denies device authorization of given normalized user code while it is pending, util.InvalidUserCode otherwise
+	DenyDeviceCode(userCode string) error
+
+	// UpdateDeviceCodePoll saves last poll time and poll interval of device authorization
+	UpdateDeviceCodePoll(code model.DeviceCodes) error
+
+	// UseDeviceCode marks device authorization of given id revoked, false when it already was
+	UseDeviceCode(id uuid.UUID) (bool, error)
+
+	// Clean removes revoked and expired tokens and codes, it runs periodically in background and may be called any time
+	Clean() (CleanReport, error)
+
+	// Close close the store
+	Close()
+}
+
+// CleanReport holds number of rows removed by one garbage collection run
+type CleanReport struct {
+	AccessTokens  int64
+	RefreshTokens int64
+	AuthCodes     int64
+	DeviceCodes   int64
+	JTIs          int64
+}
+
+// verify that Store implements TokenStore
+var _ TokenStore = (*Store)(nil)
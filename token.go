@@ -0,0 +1,384 @@
+package golang_oauth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"github.com/google/uuid"
+	"github.com/json-iterator/go"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenCodec encodes and decodes access and refresh tokens, it is embedded in Manager
+type tokenCodec struct {
+	format      string
+	algorithm   string
+	keys        KeyProvider
+	audience    []string
+	issuer      string
+	claims      ClaimsProvider
+	scopes      []string
+	events      func(event model.SecurityEvent)
+	defaultOnce sync.Once
+	defaultKeys KeyProvider
+	defaultErr  error
+}
+
+// accessClaims is decoded content of access token, UserId is 0 for tokens issued to client itself
+type accessClaims struct {
+	model.AccessTokenPayload
+	ID uuid.UUID
+}
+
+// SetTokenFormat sets format of issued access tokens,
+// format util.TokenFormatEncrypted (default) for RSA-OAEP encrypted tokens or util.TokenFormatJWT for signed JWT,
+// algorithm util.RS256 (default) or util.ES256, used only for JWT,
+// tokens of both formats are accepted regardless of this setting
+func (c *tokenCodec) SetTokenFormat(format, algorithm string) error {
+	switch format {
+	case "", util.TokenFormatEncrypted:
+		c.format = util.TokenFormatEncrypted
+		c.algorithm = ""
+	case util.TokenFormatJWT:
+		if algorithm == "" {
+			algorithm = util.RS256
+		}
+		if algorithm != util.RS256 && algorithm != util.ES256 {
+			return errors.New(util.UnsupportedAlgorithm)
+		}
+		c.format = format
+		c.algorithm = algorithm
+	default:
+		return errors.New(util.UnsupportedTokenFormat)
+	}
+	return nil
+}
+
+// SetKeyProvider sets provider of keys used to encrypt and sign tokens,
+// when it is not set keys are loaded once by NewDefaultKeyProvider
+func (c *tokenCodec) SetKeyProvider(keys KeyProvider) {
+	c.keys = keys
+}
+
+// SetSecurityEventHandler sets function called with security events such as reuse of refresh token,
+// it is called synchronously after the manager reacted to the event
+func (c *tokenCodec) SetSecurityEventHandler(handler func(event model.SecurityEvent)) {
+	c.events = handler
+}
+
+// emit passes security event to configured handler
+func (c *tokenCodec) emit(event model.SecurityEvent) {
+	if c.events != nil {
+		c.events(event)
+	}
+}
+
+// keyProvider returns configured KeyProvider or lazily loaded default one
+func (c *tokenCodec) keyProvider() (KeyProvider, error) {
+	if c.keys != nil {
+		return c.keys, nil
+	}
+	c.defaultOnce.Do(func() {
+		c.defaultKeys, c.defaultErr = NewDefaultKeyProvider()
+	})
+	return c.defaultKeys, c.defaultErr
+}
+
+// key returns active key for given algorithm from key provider
+func (c *tokenCodec) key(alg string) (*Key, error) {
+	keys, err := c.keyProvider()
+	if err != nil {
+		return nil, err
+	}
+	return keys.Key(alg)
+}
+
+// encrypt encrypts given payload with active RSA key and authenticates it with MAC keyed by the private key,
+// id of the key is prepended to cipher text, the key is published in JWKS so tokens anyone encrypted must not be accepted
+func (c *tokenCodec) encrypt(payload interface{}) (string, error) {
+	key, err := c.key(util.RS256)
+	if err != nil {
+		return "", err
+	}
+	privKey, ok := key.Signer.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New(util.InvalidSigningKey)
+	}
+	payloadByte := new(bytes.Buffer)
+	_ = json.NewEncoder(payloadByte).Encode(payload)
+	cipherText, err := util.SealWithPrivateKey(payloadByte.Bytes(), privKey)
+	if err != nil {
+		return "", err
+	}
+	return key.ID + "." + cipherText, nil
+}
+
+// decrypt verifies and decrypts token made by encrypt with key matching its key id
+func (c *tokenCodec) decrypt(token string) (string, error) {
+	i := strings.Index(token, ".")
+	if i < 0 {
+		return "", errors.New(util.InvalidSealedToken)
+	}
+	keys, err := c.keyProvider()
+	if err != nil {
+		return "", err
+	}
+	key, err := keys.KeyByID(token[:i])
+	if err != nil {
+		return "", err
+	}
+	privKey, ok := key.Signer.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New(util.InvalidSigningKey)
+	}
+	return util.OpenWithPrivateKey(token[i+1:], privKey)
+}
+
+// newTokens builds access token and refresh token for given token information,
+// familyId family of rotated refresh token, uuid.Nil starts new family,
+// returned models are not persisted, it is up to Manager to save them in its store
+func (c *tokenCodec) newTokens(info model.TokenInfo, familyId uuid.UUID) (*model.AccessTokens, *model.RefreshTokens, model.TokenResponse, error) {
+	oauthAccess, tokenResp, err := c.newAccessToken(info)
+	if err != nil {
+		return nil, nil, tokenResp, err
+	}
+
+	// set refresh
+	refreshTokenPayload := model.RefreshTokenPayload{}
+	refreshTokenPayload.AccessTokenId = oauthAccess.ID
+	refreshTokenPayload.ExpiredAt = info.GetRefreshCreateAt().Add(info.GetRefreshExpiresIn()).Unix()
+	refreshToken := &model.RefreshTokens{
+		Model: model.Model{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		RefreshTokenPayload: refreshTokenPayload,
+		FamilyId:            familyId,
+		Revoked:             false,
+	}
+	if familyId == uuid.Nil {
+		refreshToken.FamilyId = refreshToken.ID
+	}
+
+	refToken, err := c.encrypt(refreshTokenPayload)
+	if err != nil {
+		return nil, nil, tokenResp, err
+	}
+	tokenResp.RefreshToken = refToken
+	tokenResp.RefreshExpiredAt = refreshTokenPayload.ExpiredAt
+	return oauthAccess, refreshToken, tokenResp, nil
+}
+
+// newAccessToken builds access token without refresh token for given token information,
+// user id 0 issues token to the client itself
+func (c *tokenCodec) newAccessToken(info model.TokenInfo) (*model.AccessTokens, model.TokenResponse, error) {
+	accessTokenPayload := model.AccessTokenPayload{}
+	accessId := uuid.New()
+	accessTokenPayload.UserId = info.GetUserID()
+	accessTokenPayload.ClientId = info.GetClientID()
+	accessTokenPayload.ExpiredAt = info.GetAccessCreateAt().Add(info.GetAccessExpiresIn()).Unix()
+	oauthAccess := &model.AccessTokens{
+		Model: model.Model{
+			ID:        accessId,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		AccessTokenPayload: accessTokenPayload,
+		Scope:              info.GetScope(),
+		Name:               "",
+		Revoked:            false,
+	}
+	tokenResp, err := c.issueAccessToken(oauthAccess, info.GetAccessCreateAt())
+	if err != nil {
+		return nil, tokenResp, err
+	}
+	return oauthAccess, tokenResp, nil
+}
+
+// issueAccessToken encodes given access token in configured format
+func (c *tokenCodec) issueAccessToken(access *model.AccessTokens, issuedAt time.Time) (model.TokenResponse, error) {
+	tokenResp := model.TokenResponse{}
+	var accessToken string
+	var err error
+	if c.format == util.TokenFormatJWT {
+		accessToken, err = c.signAccessToken(access, issuedAt)
+	} else {
+		accessToken, err = c.encrypt(accessClaims{AccessTokenPayload: access.AccessTokenPayload, ID: access.ID})
+	}
+	if err != nil {
+		return tokenResp, err
+	}
+	tokenResp.AccessToken = accessToken
+	tokenResp.ExpiredAt = access.ExpiredAt
+	tokenResp.Scope = access.Scope
+	return tokenResp, nil
+}
+
+// tokenSubject returns subject of given access token, user id or client id for token without user
+func tokenSubject(access *model.AccessTokens) string {
+	if access.UserId != 0 {
+		return strconv.FormatInt(access.UserId, 10)
+	}
+	return access.ClientId.String()
+}
+
+// signAccessToken issues signed JWT for given access token, subject of token without user is the client id
+func (c *tokenCodec) signAccessToken(access *model.AccessTokens, issuedAt time.Time) (string, error) {
+	act, err := access.Actor()
+	if err != nil {
+		return "", err
+	}
+	claims := model.AccessTokenClaims{
+		Subject:   tokenSubject(access),
+		ClientId:  access.ClientId.String(),
+		Scope:     access.Scope,
+		Audience:  access.Audience,
+		Act:       act,
+		ExpiresAt: access.ExpiredAt,
+		IssuedAt:  issuedAt.Unix(),
+		ID:        access.ID.String(),
+	}
+	key, err := c.key(c.algorithm)
+	if err != nil {
+		return "", err
+	}
+	return util.SignJWT(claims, key.Algorithm, key.ID, key.Signer)
+}
+
+// decodeAccessToken decodes given access token, JWT is recognized by its three dot separated parts
+func (c *tokenCodec) decodeAccessToken(token string) (*accessClaims, error) {
+	if strings.Count(token, ".") == 2 {
+		return c.verifyAccessToken(token)
+	}
+	return c.decryptAccessToken(token)
+}
+
+// decodeRefreshToken decodes given refresh token
+func (c *tokenCodec) decodeRefreshToken(token string) (*model.RefreshTokenPayload, error) {
+	return c.decryptRefreshToken(token)
+}
+
+// verifyAccessToken verifies signature of given JWT access token
+func (c *tokenCodec) verifyAccessToken(token string) (*accessClaims, error) {
+	var claims model.AccessTokenClaims
+	_, err := util.ParseJWT(token, &claims, func(header util.JWTHeader) (crypto.PublicKey, error) {
+		keys, err := c.keyProvider()
+		if err != nil {
+			return nil, err
+		}
+		var key *Key
+		if header.Kid != "" {
+			key, err = keys.KeyByID(header.Kid)
+		} else {
+			key, err = keys.Key(header.Alg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key.Algorithm != header.Alg {
+			return nil, errors.New(util.InvalidSigningKey)
+		}
+		return key.Signer.Public(), nil
+	})
+	if err != nil {
+		return nil, errors.New(util.InvalidAccessToken)
+	}
+
+	clientId, err := uuid.Parse(claims.ClientId)
+	if err != nil {
+		return nil, errors.New(util.InvalidAccessToken)
+	}
+	var userId int64
+	if claims.Subject != claims.ClientId {
+		userId, err = strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil || userId == 0 {
+			return nil, errors.New(util.InvalidAccessToken)
+		}
+	}
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, errors.New(util.InvalidAccessToken)
+	}
+	return &accessClaims{
+		AccessTokenPayload: model.AccessTokenPayload{
+			UserId:    userId,
+			ClientId:  clientId,
+			ExpiredAt: claims.ExpiresAt,
+		},
+		ID: id,
+	}, nil
+}
+
+// decryptAccessToken decrypts given access token
+func (c *tokenCodec) decryptAccessToken(token string) (*accessClaims, error) {
+	var tm accessClaims
+	dec, err := c.decrypt(token)
+	if err != nil {
+		return &tm, errors.New(util.InvalidAccessToken)
+	}
+	_ = jsoniter.Unmarshal([]byte(dec), &tm)
+	if tm.ClientId == uuid.Nil || tm.ID == uuid.Nil {
+		return &tm, errors.New(util.InvalidAccessToken)
+	}
+	return &tm, nil
+}
+
+// decryptRefreshToken decrypts given refresh token
+func (c *tokenCodec) decryptRefreshToken(token string) (*model.RefreshTokenPayload, error) {
+	var tm model.RefreshTokenPayload
+	decipher, err := c.decrypt(token)
+	if err != nil {
+		return &tm, errors.New(util.InvalidRefreshToken)
+	}
+	_ = jsoniter.Unmarshal([]byte(decipher), &tm)
+	if tm.AccessTokenId == uuid.Nil {
+		return &tm, errors.New(util.InvalidRefreshToken)
+	}
+	return &tm, nil
+}
+
+// encodeAuthCode encrypts id of authorization code row into code handed to client
+func (c *tokenCodec) encodeAuthCode(id uuid.UUID) (string, error) {
+	return c.encrypt(model.AuthCodePayload{AuthCodeId: id})
+}
+
+// decodeAuthCode decrypts given authorization code
+func (c *tokenCodec) decodeAuthCode(code string) (*model.AuthCodePayload, error) {
+	var tm model.AuthCodePayload
+	decipher, err := c.decrypt(code)
+	if err != nil {
+		return &tm, errors.New(util.InvalidAuthCode)
+	}
+	_ = jsoniter.Unmarshal([]byte(decipher), &tm)
+	if tm.AuthCodeId == uuid.Nil {
+		return &tm, errors.New(util.InvalidAuthCode)
+	}
+	return &tm, nil
+}
+
+// encodeDeviceCode encrypts id of device authorization row into device code handed to client
+func (c *tokenCodec) encodeDeviceCode(id uuid.UUID) (string, error) {
+	return c.encrypt(model.DeviceCodePayload{DeviceCodeId: id})
+}
+
+// decodeDeviceCode decrypts given device code
+func (c *tokenCodec) decodeDeviceCode(code string) (*model.DeviceCodePayload, error) {
+	var tm model.DeviceCodePayload
+	decipher, err := c.decrypt(code)
+	if err != nil {
+		return &tm, errors.New(util.InvalidDeviceCode)
+	}
+	_ = jsoniter.Unmarshal([]byte(decipher), &tm)
+	if tm.DeviceCodeId == uuid.Nil {
+		return &tm, errors.New(util.InvalidDeviceCode)
+	}
+	return &tm, nil
+}
@@ -0,0 +1,96 @@
+package golang_oauth
+
+import (
+	"crypto"
+	"github.com/gobeam/golang-oauth/model"
+	"github.com/gobeam/golang-oauth/util"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testKeyProvider generates in-memory keys for tests
+func testKeyProvider(t *testing.T) KeyProvider {
+	rsaKey, _ := util.GenerateKeyPair(util.BitSize)
+	ecKey, err := util.GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keys, err := NewKeyProvider(rsaKey, ecKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return keys
+}
+
+func TestJWTAccessToken(t *testing.T) {
+	keys := testKeyProvider(t)
+	for _, alg := range []string{util.RS256, util.ES256} {
+		store := NewManager(NewDefaultMemoryStore())
+		store.SetKeyProvider(keys)
+		if err := store.SetTokenFormat(util.TokenFormatJWT, alg); err != nil {
+			t.Fatal(err.Error())
+		}
+		client, err := store.CreateClient(userID, "jwt app")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		resp, err := store.Create(&model.Token{
+			ClientID:        client.ID,
+			ClientSecret:    client.Secret,
+			UserID:          userID,
+			Scope:           "read",
+			AccessCreateAt:  time.Now(),
+			AccessExpiresIn: time.Minute,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// resource servers verify the token offline with public key only
+		var claims model.AccessTokenClaims
+		header, err := util.ParseJWT(resp.AccessToken, &claims, func(header util.JWTHeader) (crypto.PublicKey, error) {
+			key, err := keys.KeyByID(header.Kid)
+			if err != nil {
+				return nil, err
+			}
+			return key.Signer.Public(), nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		if header.Alg != alg {
+			t.Errorf("expected alg %s, got %s", alg, header.Alg)
+		}
+		if claims.Subject != strconv.FormatInt(userID, 10) || claims.ClientId != client.ID.String() || claims.Scope != "read" ||
+			claims.ExpiresAt != resp.ExpiredAt || claims.IssuedAt == 0 || claims.ID == "" {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+
+		item, err := store.GetByAccess(resp.AccessToken)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if item.ID.String() != claims.ID {
+			t.Errorf("expected access token %s, got %s", claims.ID, item.ID)
+		}
+		if _, err := store.GetByRefresh(resp.RefreshToken); err != nil {
+			t.Fatal(err.Error())
+		}
+		if _, err := store.GetByAccess(resp.AccessToken); err == nil || err.Error() != util.AccessTokenRevoked {
+			t.Errorf("expected %q, got %v", util.AccessTokenRevoked, err)
+		}
+		store.Close()
+	}
+}
+
+func TestSetTokenFormat(t *testing.T) {
+	store := NewManager(NewDefaultMemoryStore())
+	defer store.Close()
+	if err := store.SetTokenFormat("opaque", ""); err == nil || err.Error() != util.UnsupportedTokenFormat {
+		t.Errorf("expected %q, got %v", util.UnsupportedTokenFormat, err)
+	}
+	if err := store.SetTokenFormat(util.TokenFormatJWT, "HS256"); err == nil || err.Error() != util.UnsupportedAlgorithm {
+		t.Errorf("expected %q, got %v", util.UnsupportedAlgorithm, err)
+	}
+}
@@ -2,21 +2,130 @@ package util
 
 // constants
 const (
-	PublicPem           = "public.pem"
-	PrivatePem          = "private.pem"
-	AccessTokenTable    = "oauth_access_tokens"
-	RefreshTokenTable   = "oauth_refresh_tokens"
-	ClientTable         = "oauth_clients"
-	BitSize             = 2048
-	RefreshTokenRevoked = "refresh token already been revoked"
-	AccessTokenRevoked  = "access token has already been revoked"
-	AccessTokenExpired  = "access token has already been expired"
-	InvalidRefreshToken = "invalid refresh token"
-	InvalidAccessToken  = "invalid access token"
-	InvalidClient       = "invalid client"
-	EmptyUserID         = "user id cannot be empty"
-	Label               = "OAEP Encrypted"
-	PublicKey           = "PUBLIC KEY"
-	PrivateKey          = "PRIVATE KEY"
-	DbConfig            = "root:@tcp(127.0.0.1:3306)/goauth?charset=utf8&parseTime=True&loc=Local"
+	PublicPem              = "public.pem"
+	PrivatePem             = "private.pem"
+	AccessTokenTable       = "oauth_access_tokens"
+	RefreshTokenTable      = "oauth_refresh_tokens"
+	ClientTable            = "oauth_clients"
+	AuthCodeTable          = "oauth_auth_codes"
+	DeviceCodeTable        = "oauth_device_codes"
+	BitSize                = 2048
+	RefreshTokenRevoked    = "refresh token already been revoked"
+	RefreshTokenExpired    = "refresh token has already been expired"
+	AccessTokenExpiry      = 3600
+	RefreshTokenExpiry     = 2592000
+	AccessTokenRevoked     = "access token has already been revoked"
+	AccessTokenExpired     = "access token has already been expired"
+	InvalidRefreshToken    = "invalid refresh token"
+	InvalidAccessToken     = "invalid access token"
+	InvalidClient          = "invalid client"
+	EmptyUserID            = "user id cannot be empty"
+	Label                  = "OAEP Encrypted"
+	PublicKey              = "PUBLIC KEY"
+	PrivateKey             = "PRIVATE KEY"
+	DbConfig               = "root:@tcp(127.0.0.1:3306)/goauth?charset=utf8&parseTime=True&loc=Local"
+	TokenFormatEncrypted   = "encrypted"
+	TokenFormatJWT         = "jwt"
+	RS256                  = "RS256"
+	ES256                  = "ES256"
+	ECPrivatePem           = "ec_private.pem"
+	InvalidJWT             = "invalid jwt"
+	InvalidJWTSignature    = "invalid jwt signature"
+	InvalidSigningKey      = "signing key does not match algorithm"
+	UnsupportedAlgorithm   = "unsupported signing algorithm"
+	UnsupportedTokenFormat = "unsupported token format"
+	InvalidPEM             = "invalid pem encoded key"
+	MissingKey             = "key is not configured"
+	UnknownKey             = "unknown key id"
+	InvalidJWK             = "invalid json web key"
+	JWKSPath               = "/.well-known/jwks.json"
+	InvalidAuthCode        = "invalid authorization code"
+	AuthCodeExpired        = "authorization code has already been expired"
+	AuthCodeUsed           = "authorization code has already been used"
+	InvalidRedirectURI     = "invalid redirect uri"
+	InvalidCodeChallenge   = "invalid code challenge"
+	InvalidCodeVerifier    = "invalid code verifier"
+	MissingCodeChallenge   = "code challenge is required for public client"
+	InvalidScope           = "requested scope is not allowed for client"
+	UnauthorizedClient     = "client is not allowed to use this grant"
+	InvalidDeviceCode      = "invalid device code"
+	InvalidUserCode        = "invalid user code"
+	AuthorizationPending   = "authorization_pending"
+	SlowDown               = "slow_down"
+	ExpiredToken           = "expired_token"
+	AccessDenied           = "access_denied"
+	DeviceCodeExpiry       = 600
+	DeviceCodeInterval     = 5
+	UserCodeLength         = 8
+	UnsupportedTokenType   = "unsupported token type"
+	ExcessiveScope         = "requested scope exceeds scope of subject token"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+	PKCES256               = "S256"
+	PKCEPlain              = "plain"
+	AuthCodeExpiry         = 600
+	JTITable               = "oauth_jwt_ids"
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	ClientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	UnsupportedAssertion   = "unsupported client assertion type"
+	InvalidAssertion       = "invalid assertion"
+	AssertionExpired       = "assertion has already been expired"
+	AssertionReplayed      = "assertion has already been used"
+	MissingAudience        = "assertion audience is not configured"
+	InvalidJWKS            = "invalid json web key set"
+	MaxAssertionLifetime   = 3600
+	ClockSkew              = 60
+	GCBatchSize            = 1000
+	GCLeaseTable           = "oauth_gc_leases"
+	GCLeaseName            = "gc"
+	IntrospectionPath      = "/introspect"
+	TokenTypeHintAccess    = "access_token"
+	TokenTypeHintRefresh   = "refresh_token"
+	TokenTypeBearer        = "Bearer"
+	ErrorInvalidRequest    = "invalid_request"
+	ErrorInvalidClient     = "invalid_client"
+	ErrorServerError       = "server_error"
+	RevocationPath         = "/revoke"
+	TokenPath              = "/token"
+	ErrorInvalidGrant      = "invalid_grant"
+	ErrorInvalidScope      = "invalid_scope"
+	ErrorUnauthorized      = "unauthorized_client"
+	ErrorUnsupportedGrant  = "unsupported_grant_type"
+	GrantPassword          = "password"
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+	GrantJWTBearer         = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	ScopeOpenID            = "openid"
+	MissingOpenIDScope     = "access token was not issued for openid scope"
+	UserInfoPath           = "/userinfo"
+	ErrorInvalidToken      = "invalid_token"
+	ErrorInsufficientScope = "insufficient_scope"
+	OpenIDConfigPath       = "/.well-known/openid-configuration"
+	ServerMetadataPath     = "/.well-known/oauth-authorization-server"
+	ResponseTypeCode       = "code"
+	SubjectTypePublic      = "public"
+	AuthMethodBasic        = "client_secret_basic"
+	AuthMethodPost         = "client_secret_post"
+	AuthMethodPrivateKey   = "private_key_jwt"
+	AuthMethodNone         = "none"
+	RegistrationPath       = "/register"
+	RegistrationTokenSize  = 32
+	InvalidClientMetadata  = "invalid client metadata"
+	UnsupportedGrantType   = "unsupported grant type"
+	UnsupportedAuthMethod  = "unsupported token endpoint authentication method"
+	InvalidRegistration    = "invalid registration access token"
+	ErrorInvalidMetadata   = "invalid_client_metadata"
+	ErrorInvalidRedirect   = "invalid_redirect_uri"
+	SecretHashCost         = 10
+	ClientSecretSize       = 24
+	ClientWithoutSecret    = "client has no secret to rotate"
+	ConcurrentRotation     = "client secret was changed by another request"
+	InvalidSealedToken     = "token was not issued by this server"
+	DriverMySQL            = "mysql"
+	DriverPostgres         = "postgres"
+	DriverSQLite           = "sqlite3"
+	UserCodeInUse          = "user code is already in use"
 )
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// UserCodeCharacters is user code alphabet, consonants only so codes are easy to type and never spell words (RFC 8628 6.1)
+var UserCodeCharacters = []byte("BCDFGHJKLMNPQRSTVWXZ")
+
+// RandomUserCode generates random user code of given length from UserCodeCharacters using crypto/rand
+func RandomUserCode(length int) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(UserCodeCharacters)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = UserCodeCharacters[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeUserCode normalizes user code typed by user, case and separators are ignored
+func NormalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// FormatUserCode formats normalized user code for display by splitting it in half with dash, e.g. WDJB-MJHT
+func FormatUserCode(code string) string {
+	if len(code) < 2 {
+		return code
+	}
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUserCode(t *testing.T) {
+	code, err := RandomUserCode(UserCodeLength)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	formatted := FormatUserCode(code)
+	if len(formatted) != UserCodeLength+1 || formatted[UserCodeLength/2] != '-' {
+		t.Errorf("unexpected formatted user code %s", formatted)
+	}
+	if NormalizeUserCode(" "+strings.ToLower(formatted)) != code {
+		t.Errorf("user code %s does not normalize back to %s", formatted, code)
+	}
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JWK is RFC 7517 JSON Web Key holding public RSA or P-256 key
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is RFC 7517 JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK converts given RSA or P-256 public key to JWK,
+// alg algorithm the key is used with, kid key id, use intended use ("sig" or "enc")
+func NewJWK(pub crypto.PublicKey, alg, kid, use string) (JWK, error) {
+	jwk := JWK{Use: use, Alg: alg, Kid: kid}
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return jwk, errors.New(UnsupportedAlgorithm)
+		}
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(padBytes(key.X.Bytes(), 32))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(padBytes(key.Y.Bytes(), 32))
+	default:
+		return jwk, errors.New(UnsupportedAlgorithm)
+	}
+	return jwk, nil
+}
+
+// PublicKey converts JWK back to *rsa.PublicKey or *ecdsa.PublicKey
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := func(v string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New(InvalidJWK)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New(InvalidJWK)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New(UnsupportedAlgorithm)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New(InvalidJWK)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, errors.New(UnsupportedAlgorithm)
+	}
+}
+
+// JWKThumbprint computes RFC 7638 SHA-256 thumbprint of given RSA or P-256 public key,
+// it is used as key id so every replica derives the same kid for the same key
+func JWKThumbprint(pub crypto.PublicKey) (string, error) {
+	var members string
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`,
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes()))
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", errors.New(UnsupportedAlgorithm)
+		}
+		members = fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+			base64.RawURLEncoding.EncodeToString(padBytes(key.X.Bytes(), 32)),
+			base64.RawURLEncoding.EncodeToString(padBytes(key.Y.Bytes(), 32)))
+	default:
+		return "", errors.New(UnsupportedAlgorithm)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// padBytes left pads given big endian bytes with zeros to size
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+// NewJWKSet converts given RSA or P-256 public keys to JWK Set with RFC 7638 thumbprints as key ids,
+// used to register public keys of a client
+func NewJWKSet(keys ...crypto.PublicKey) (JWKSet, error) {
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, pub := range keys {
+		kid, err := JWKThumbprint(pub)
+		if err != nil {
+			return set, err
+		}
+		jwk, err := NewJWK(pub, "", kid, "sig")
+		if err != nil {
+			return set, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestJWK(t *testing.T) {
+	rsaKey, _ := GenerateKeyPair(BitSize)
+	jwk, err := NewJWK(rsaKey.Public(), RS256, "kid-1", "sig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if jwk.Kty != "RSA" || jwk.E != "AQAB" || jwk.Kid != "kid-1" {
+		t.Errorf("unexpected jwk %+v", jwk)
+	}
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rsaPub := pub.(*rsa.PublicKey); rsaPub.N.Cmp(rsaKey.N) != 0 || rsaPub.E != rsaKey.E {
+		t.Error("rsa public key does not round trip")
+	}
+
+	ecKey, err := GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	jwk, err = NewJWK(ecKey.Public(), ES256, "kid-2", "sig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" || len(jwk.X) != 43 || len(jwk.Y) != 43 {
+		t.Errorf("unexpected jwk %+v", jwk)
+	}
+	pub, err = jwk.PublicKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ecPub := pub.(*ecdsa.PublicKey); ecPub.X.Cmp(ecKey.X) != 0 || ecPub.Y.Cmp(ecKey.Y) != 0 {
+		t.Error("ec public key does not round trip")
+	}
+
+	jwk.Y = jwk.X
+	if _, err := jwk.PublicKey(); err == nil {
+		t.Error("expected error for point not on curve")
+	}
+}
+
+func TestNewJWKSet(t *testing.T) {
+	rsaKey, _ := GenerateKeyPair(BitSize)
+	ecKey, err := GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	set, err := NewJWKSet(rsaKey.Public(), ecKey.Public())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	thumbprint, _ := JWKThumbprint(ecKey.Public())
+	if len(set.Keys) != 2 || set.Keys[0].Kty != "RSA" || set.Keys[1].Kid != thumbprint || set.Keys[1].Alg != "" {
+		t.Errorf("unexpected jwk set %+v", set)
+	}
+	if _, err := NewJWKSet("not a key"); err == nil {
+		t.Error("expected error for unsupported key")
+	}
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// JWTHeader is JOSE header of signed JWT
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// SignJWT signs given claims with key using alg (RS256 or ES256) and returns compact serialized JWT,
+// kid is embedded in header when not empty
+func SignJWT(claims interface{}, alg, kid string, key crypto.Signer) (string, error) {
+	header, err := json.Marshal(JWTHeader{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case RS256:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New(InvalidSigningKey)
+		}
+		signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+		if err != nil {
+			return "", err
+		}
+	case ES256:
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return "", errors.New(InvalidSigningKey)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, hash[:])
+		if err != nil {
+			return "", err
+		}
+		signature = append(padBytes(r.Bytes(), 32), padBytes(s.Bytes(), 32)...)
+	default:
+		return "", errors.New(UnsupportedAlgorithm)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ParseJWT verifies compact serialized JWT with public key returned by keyFunc for its header,
+// and unmarshals verified payload into claims
+func ParseJWT(token string, claims interface{}, keyFunc func(header JWTHeader) (crypto.PublicKey, error)) (JWTHeader, error) {
+	var header JWTHeader
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header, errors.New(InvalidJWT)
+	}
+	headerByte, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return header, errors.New(InvalidJWT)
+	}
+	if err := json.Unmarshal(headerByte, &header); err != nil {
+		return header, errors.New(InvalidJWT)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return header, errors.New(InvalidJWT)
+	}
+	key, err := keyFunc(header)
+	if err != nil {
+		return header, err
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch header.Alg {
+	case RS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return header, errors.New(InvalidSigningKey)
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, hash[:], signature); err != nil {
+			return header, errors.New(InvalidJWTSignature)
+		}
+	case ES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve != elliptic.P256() || len(signature) != 64 {
+			return header, errors.New(InvalidJWTSignature)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, hash[:], r, s) {
+			return header, errors.New(InvalidJWTSignature)
+		}
+	default:
+		return header, errors.New(UnsupportedAlgorithm)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return header, errors.New(InvalidJWT)
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return header, errors.New(InvalidJWT)
+	}
+	return header, nil
+}
+
+// DecodeJWTClaims unmarshals payload of compact serialized JWT into claims WITHOUT verifying its signature,
+// it may only be used to find the key the JWT must then be verified with by ParseJWT
+func DecodeJWTClaims(token string, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New(InvalidJWT)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return errors.New(InvalidJWT)
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return errors.New(InvalidJWT)
+	}
+	return nil
+}
+
+// GenerateECKey generates a new P-256 ecdsa key used for ES256 signatures
+func GenerateECKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// ECPrivateKeyToBytes pem encodes given *ecdsa.PrivateKey
+func ECPrivateKeyToBytes(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// BytesToECPrivateKey converts given pem bytes to *ecdsa.PrivateKey
+func BytesToECPrivateKey(priv []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(priv)
+	if block == nil {
+		return nil, errors.New(InvalidPEM)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
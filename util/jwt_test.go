@@ -0,0 +1,58 @@
+package util
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+)
+
+type testClaims struct {
+	Subject string `json:"sub"`
+}
+
+func TestSignJWT(t *testing.T) {
+	rsaKey, _ := GenerateKeyPair(BitSize)
+	ecKey, err := GenerateECKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keys := map[string]crypto.Signer{RS256: rsaKey, ES256: ecKey}
+	for alg, key := range keys {
+		token, err := SignJWT(testClaims{Subject: "1"}, alg, "kid-1", key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var claims testClaims
+		header, err := ParseJWT(token, &claims, func(header JWTHeader) (crypto.PublicKey, error) {
+			return key.Public(), nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", alg, err.Error())
+		}
+		if header.Kid != "kid-1" || header.Alg != alg || claims.Subject != "1" {
+			t.Errorf("%s: unexpected header %+v and claims %+v", alg, header, claims)
+		}
+
+		parts := strings.Split(token, ".")
+		tampered := parts[0] + "." + strings.TrimRight(parts[1], "=") + "x." + parts[2]
+		if _, err := ParseJWT(tampered, &claims, func(header JWTHeader) (crypto.PublicKey, error) {
+			return key.Public(), nil
+		}); err == nil {
+			t.Errorf("%s: tampered token must not verify", alg)
+		}
+	}
+
+	if _, err := SignJWT(testClaims{}, ES256, "", rsaKey); err == nil || err.Error() != InvalidSigningKey {
+		t.Errorf("expected %q, got %v", InvalidSigningKey, err)
+	}
+}
+
+func TestParseJWTRejectsNone(t *testing.T) {
+	token := "eyJhbGciOiJub25lIn0.eyJzdWIiOiIxIn0."
+	var claims testClaims
+	if _, err := ParseJWT(token, &claims, func(header JWTHeader) (crypto.PublicKey, error) {
+		return nil, nil
+	}); err == nil {
+		t.Error("alg none must be rejected")
+	}
+}
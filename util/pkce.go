@@ -0,0 +1,58 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+)
+
+// ValidateCodeChallenge validates PKCE code challenge sent with authorization request (RFC 7636 4.2),
+// method PKCES256 or PKCEPlain, empty method means PKCEPlain
+func ValidateCodeChallenge(challenge, method string) error {
+	if method != "" && method != PKCES256 && method != PKCEPlain {
+		return errors.New(InvalidCodeChallenge)
+	}
+	if !isPKCEString(challenge) {
+		return errors.New(InvalidCodeChallenge)
+	}
+	return nil
+}
+
+// VerifyCodeVerifier verifies PKCE code verifier sent to token endpoint against stored code challenge (RFC 7636 4.6),
+// comparison is constant time
+func VerifyCodeVerifier(verifier, challenge, method string) error {
+	if !isPKCEString(verifier) {
+		return errors.New(InvalidCodeVerifier)
+	}
+	var expected string
+	switch method {
+	case PKCES256:
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	case "", PKCEPlain:
+		expected = verifier
+	default:
+		return errors.New(InvalidCodeChallenge)
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) != 1 {
+		return errors.New(InvalidCodeVerifier)
+	}
+	return nil
+}
+
+// isPKCEString reports whether s is 43 to 128 characters of [A-Z] / [a-z] / [0-9] / "-" / "." / "_" / "~"
+func isPKCEString(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
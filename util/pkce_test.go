@@ -0,0 +1,30 @@
+package util
+
+import "testing"
+
+func TestVerifyCodeVerifier(t *testing.T) {
+	// example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if err := ValidateCodeChallenge(challenge, PKCES256); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := VerifyCodeVerifier(verifier, challenge, PKCES256); err != nil {
+		t.Error(err.Error())
+	}
+	if err := VerifyCodeVerifier(verifier, verifier, PKCEPlain); err != nil {
+		t.Error(err.Error())
+	}
+	if err := VerifyCodeVerifier(verifier, challenge, PKCEPlain); err == nil {
+		t.Error("expected plain verifier not matching challenge to fail")
+	}
+	if err := VerifyCodeVerifier(verifier[:42], challenge, PKCES256); err == nil {
+		t.Error("expected short verifier to fail")
+	}
+	if err := ValidateCodeChallenge(challenge, "S512"); err == nil {
+		t.Error("expected unsupported method to fail")
+	}
+	if err := ValidateCodeChallenge(challenge+"!", PKCES256); err == nil {
+		t.Error("expected invalid characters to fail")
+	}
+}
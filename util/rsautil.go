@@ -1,12 +1,15 @@
 package util
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
 	rand2 "math/rand"
@@ -26,6 +29,21 @@ func RandomKey(length int) string {
 	return string(bytes)
 }
 
+// RandomToken generates opaque token of given number of random bytes using crypto/rand, base64url encoded
+func RandomToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// HashToken returns base64url encoded SHA-256 hash of token, tokens are stored hashed so a database dump does not reveal them
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 // randInt generates a random integer between min and max.
 func randInt(min int, max int) int {
 	return min + rand2.Intn(max-min)
@@ -154,3 +172,73 @@ func DecryptWithPrivateKey(cipherText string, priv *rsa.PrivateKey) (string, err
 
 	return string(plaintext), nil
 }
+
+// SealWithPrivateKey encrypts given []byte with public part of private key and authenticates the cipher text
+// with HMAC keyed by the private key, the public key may be published so encryption alone proves nothing
+func SealWithPrivateKey(msg []byte, priv *rsa.PrivateKey) (string, error) {
+	cipherText, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &priv.PublicKey, msg, []byte(Label))
+	if err != nil {
+		return "", err
+	}
+	sealed := append(cipherText, sealMAC(priv, cipherText)...)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenWithPrivateKey verifies and decrypts cipher text made by SealWithPrivateKey
+func OpenWithPrivateKey(sealed string, priv *rsa.PrivateKey) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) != priv.Size()+sha256.Size {
+		return "", errors.New(InvalidSealedToken)
+	}
+	cipherText, mac := b[:priv.Size()], b[priv.Size():]
+	if subtle.ConstantTimeCompare(mac, sealMAC(priv, cipherText)) != 1 {
+		return "", errors.New(InvalidSealedToken)
+	}
+	plainText, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, cipherText, []byte(Label))
+	if err != nil {
+		return "", errors.New(InvalidSealedToken)
+	}
+	return string(plainText), nil
+}
+
+// sealMAC returns HMAC-SHA256 of cipher text keyed by hash of private exponent, replicas sharing the key derive the same MAC key
+func sealMAC(priv *rsa.PrivateKey, cipherText []byte) []byte {
+	key := sha256.Sum256(append([]byte(Label), priv.D.Bytes()...))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(cipherText)
+	return mac.Sum(nil)
+}
+
+// ParseRSAPrivateKey converts given pem bytes to *rsa.PrivateKey, both PKCS1 and PKCS8 encodings are accepted
+func ParseRSAPrivateKey(priv []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(priv)
+	if block == nil {
+		return nil, errors.New(InvalidPEM)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New(InvalidPEM)
+	}
+	return key, nil
+}
+
+// RSAPrivateKeyToBytes pem encodes given *rsa.PrivateKey
+func RSAPrivateKeyToBytes(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// RSAPublicKeyToBytes pem encodes given *rsa.PublicKey the way SavePublicPEMKey does
+func RSAPublicKeyToBytes(key *rsa.PublicKey) ([]byte, error) {
+	pubASN1, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pubASN1}), nil
+}
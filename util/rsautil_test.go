@@ -77,3 +77,17 @@ func TestDecryptWithPrivateKey(t *testing.T) {
 		t.Errorf("Decrypted message should be %s but instead got %s", message, data)
 	}
 }
+
+func TestSealWithPrivateKey(t *testing.T) {
+	sealed, err := SealWithPrivateKey([]byte(message), privateKeyByte)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if data, err := OpenWithPrivateKey(sealed, privateKeyByte); err != nil || data != message {
+		t.Errorf("expected %s, got %s %v", message, data, err)
+	}
+	// cipher text anyone can make with the public key is rejected
+	if _, err := OpenWithPrivateKey(encryptedData, privateKeyByte); err == nil || err.Error() != InvalidSealedToken {
+		t.Errorf("expected %s, got %v", InvalidSealedToken, err)
+	}
+}
@@ -0,0 +1,25 @@
+package util
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// HashSecret returns bcrypt hash of client secret, only the hash is stored so a database dump does not reveal secrets
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), SecretHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// VerifySecret reports whether secret matches bcrypt hash, the comparison takes constant time
+func VerifySecret(hash, secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+// IsSecretHash reports whether stored value is bcrypt hash rather than plain text secret of older versions
+func IsSecretHash(value string) bool {
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
@@ -0,0 +1,22 @@
+package util
+
+import "testing"
+
+func TestHashSecret(t *testing.T) {
+	hash, err := HashSecret("s3cret")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if hash == "s3cret" || !IsSecretHash(hash) {
+		t.Fatalf("expected bcrypt hash, got %s", hash)
+	}
+	if !VerifySecret(hash, "s3cret") {
+		t.Error("secret must match its hash")
+	}
+	if VerifySecret(hash, "s3cre") || VerifySecret(hash, "") || VerifySecret("s3cret", "s3cret") {
+		t.Error("wrong secret or plain text value must not match")
+	}
+	if IsSecretHash("plaintext") {
+		t.Error("plain text secret must not be taken for hash")
+	}
+}